@@ -21,7 +21,7 @@ type API struct {
 	configSource string
 	configPath   string
 
-	lastRefresh sync.Map // map[string]time.Time
+	tasksMu sync.Mutex
 }
 
 // NewAPI returns a new API instance.
@@ -48,9 +48,9 @@ func NewAPI(db *sql.DB, configSource, configPath string, dataDir string) (*API,
 
 	go func() {
 		for now := range time.Tick(5 * time.Second) {
-			err = api.Tick(now)
+			err := api.Tick(now)
 			if err != nil {
-				log.Fatal(err)
+				log.Println(fmt.Errorf("tick: %w", err))
 			}
 		}
 	}()
@@ -83,11 +83,14 @@ func (api *API) Handler() http.Handler {
 	api.handle("GET", "/api/datasets", api.getDatasets)
 	api.handle("GET", "/api/datasets/{dataset_name}/preview", api.getDatasetPreview)
 	api.handle("GET", "/api/datasets/{dataset_name}/download", api.getDatasetDownload)
+	api.handle("GET", "/api/datasets/{dataset_name}/refreshes", api.getDatasetRefreshes)
 	api.handle("GET", "/api/status/summary", api.getStatusSummary)
 	api.handle("GET", "/api/workflows", api.getWorkflows)
 	api.handle("GET", "/api/workflows/{workflow_id}", api.getWorkflow)
 	api.handle("GET", "/api/workflows/{workflow_id}/runs", api.getWorkflowRuns)
 	api.handle("GET", "/api/workflows/{workflow_id}/runs/{workflow_run_id}/tasks", api.getWorkflowRunTasks)
+	api.handle("GET", "/api/workflows/{workflow_id}/runs/{workflow_run_id}/tasks/{task_id}/attempts", api.getWorkflowRunTaskAttempts)
+	api.handle("POST", "/api/workflows/{workflow_id}/runs/{workflow_run_id}/rerun", api.postWorkflowRunRerun)
 	api.handle("POST", "/api/workflows/{workflow_id}/start", api.postWorkflowsStart)
 	return baseMux
 }
@@ -96,6 +99,10 @@ func (api *API) handle(method, route string, handler func(_ http.ResponseWriter,
 	api.router.Methods(method).Path(route).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Println("handling", r.Method, r.URL.String())
 		resp := handler(w, r)
+		if resp.customResponse {
+			// The handler already wrote the response.
+			return
+		}
 		if resp.Error == "" {
 			resp.OK = true
 		}
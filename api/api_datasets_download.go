@@ -1,11 +1,12 @@
 package api
 
 import (
+	"database/sql"
 	"io"
 	"net/http"
 	"os"
-	"path/filepath"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
@@ -13,7 +14,30 @@ func (api *API) getDatasetDownload(w http.ResponseWriter, r *http.Request) Respo
 	vars := mux.Vars(r)
 	datasetName := vars["dataset_name"]
 
-	filename := filepath.Join(api.dataDir, datasetName+".db")
+	version := r.URL.Query().Get("version")
+	if version != "" {
+		if _, err := uuid.Parse(version); err != nil {
+			return Response{
+				Status: http.StatusBadRequest,
+				Error:  "invalid version",
+			}
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "sqlite", "parquet":
+	default:
+		return Response{
+			Status: http.StatusBadRequest,
+			Error:  "unsupported format",
+		}
+	}
+
+	filename := api.datasetFilename(datasetName, version)
+	if format == "parquet" {
+		return api.getDatasetDownloadParquet(w, datasetName, filename)
+	}
 	f, err := os.Open(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -39,3 +63,60 @@ func (api *API) getDatasetDownload(w http.ResponseWriter, r *http.Request) Respo
 
 	return CustomResponse()
 }
+
+// getDatasetDownloadParquet converts a dataset file to Parquet. The file
+// is written to a temporary file first so errors can still be returned.
+func (api *API) getDatasetDownloadParquet(w http.ResponseWriter, datasetName, filename string) Response {
+	if _, err := os.Stat(filename); err != nil {
+		if os.IsNotExist(err) {
+			return Response{
+				Status: http.StatusNotFound,
+			}
+		}
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+filename+"?mode=ro")
+	if err != nil {
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+	defer db.Close()
+
+	f, err := os.CreateTemp("", datasetName+"-*.parquet")
+	if err != nil {
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = writeParquet(f, db, datasetName)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+
+	w.Header().Add("content-type", "application/vnd.apache.parquet")
+	_, err = io.Copy(w, f)
+	if err != nil {
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+
+	return CustomResponse()
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (api *API) getDatasetRefreshes(_ http.ResponseWriter, r *http.Request) Response {
+	vars := mux.Vars(r)
+	datasetName := vars["dataset_name"]
+
+	rows, err := api.db.Query(`SELECT
+		id,
+		config_hash,
+		started_at,
+		completed_at,
+		success,
+		error,
+		COALESCE(row_counts, '{}'),
+		file_size
+	FROM dataset_refreshes WHERE dataset_id = $1 ORDER BY started_at DESC, rowid DESC LIMIT 100`,
+		datasetName)
+	if err != nil {
+		log.Println(err)
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+	defer rows.Close()
+	refreshes := []DatasetRefresh{}
+	for rows.Next() {
+		refresh := DatasetRefresh{
+			DatasetID: datasetName,
+		}
+		rowCounts := ""
+		err = rows.Scan(&refresh.ID, &refresh.ConfigHash, &refresh.StartedAt, &refresh.CompletedAt,
+			&refresh.Success, &refresh.Error, &rowCounts, &refresh.FileSize)
+		if err == nil {
+			err = json.Unmarshal([]byte(rowCounts), &refresh.RowCounts)
+		}
+		if err != nil {
+			log.Println(err)
+			return Response{
+				Status: http.StatusInternalServerError,
+				Error:  err.Error(),
+			}
+		}
+		refreshes = append(refreshes, refresh)
+	}
+	return Response{
+		Response: refreshes,
+	}
+}
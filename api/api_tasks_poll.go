@@ -31,9 +31,12 @@ func (api *API) getTasksPoll(_ http.ResponseWriter, r *http.Request) Response {
 	var taskInput []byte
 	log.Println("querying for a task")
 	err = api.db.QueryRow("select id, workflow_run_id, workflow_task_id, input from tasks where "+
+		"type = 'container' and "+
 		"completed_at is null and "+
+		"(run_at is null or run_at <= datetime('now')) and "+
 		"attempts_left > 0 and "+
-		"(started_at is null OR timeout_at < datetime('now')) "+
+		"(started_at is null OR timeout_at < datetime('now')) and "+
+		"workflow_run_id in (select id from workflow_runs where completed_at is null) "+
 		"limit 1").
 		Scan(&t.ID, &workflowRunID, &workflowTaskID, &taskInput)
 	if err == sql.ErrNoRows {
@@ -86,12 +89,36 @@ func (api *API) getTasksPoll(_ http.ResponseWriter, r *http.Request) Response {
 	t.Script = task.Script
 	t.Env = task.Env
 	t.Datasets = task.WithDatasets
+	t.DatasetVersions, err = api.workflowRunDatasetVersions(workflowRunID)
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return Response{
+			OK:     false,
+			Error:  err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
 
 	log.Println("marking task as started")
 	_, err = tx.Exec("update tasks set started_at = datetime('now'), "+
-		"timeout_at = datetime('now', '+5 minute'), "+
+		"timeout_at = datetime('now', $1), "+
 		"attempts_left = attempts_left-1 "+
-		"where id = $1", t.ID)
+		"where id = $2", sqliteModifier(task.TimeoutDuration()), t.ID)
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return Response{
+			OK:     false,
+			Error:  err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+	runner := r.URL.Query().Get("runner")
+	if runner == "" {
+		runner = r.RemoteAddr
+	}
+	t.AttemptID, err = startTaskAttempt(tx, t.ID, runner)
 	if err != nil {
 		log.Println(err)
 		tx.Rollback()
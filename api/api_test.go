@@ -0,0 +1,241 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestAPI returns an API with the given config and its own database and
+// data directory. Unlike NewAPI, it doesn't refresh datasets or run tasks
+// in the background.
+func newTestAPI(t *testing.T, conf string) *API {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "crossjoin.db")+"?_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	err = setupDatabase(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "crossjoin.yaml")
+	err = os.WriteFile(configPath, []byte(conf), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &API{
+		db:           db,
+		router:       mux.NewRouter(),
+		dataDir:      dir,
+		configSource: "file",
+		configPath:   configPath,
+	}
+	err = api.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Register the routes of the API.
+	api.Handler()
+	return api
+}
+
+// startTestRun starts a run of a workflow of the latest config.
+func startTestRun(t *testing.T, api *API, workflowID string, input map[string]interface{}) string {
+	t.Helper()
+	hash, err := api.LatestConfigHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflowRunID, err := api.startWorkflowRun(hash, workflowID, input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return workflowRunID
+}
+
+// claimTestTask starts an attempt of a scheduled task of a workflow run,
+// like a runner polling for it, and returns the IDs of the task and the
+// attempt.
+func claimTestTask(t *testing.T, api *API, workflowRunID, workflowTaskID string) (string, string) {
+	t.Helper()
+	taskID := ""
+	err := api.db.QueryRow("select id from tasks where workflow_run_id = $1 and workflow_task_id = $2 and completed_at is null",
+		workflowRunID, workflowTaskID).Scan(&taskID)
+	if err != nil {
+		t.Fatalf("task %s isn't scheduled: %s", workflowTaskID, err)
+	}
+	_, err = api.db.Exec("update tasks set started_at = datetime('now'), timeout_at = datetime('now', '+1 hour'), "+
+		"attempts_left = attempts_left-1 where id = $1", taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attemptID, err := startTaskAttempt(api.db, taskID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return taskID, attemptID
+}
+
+// runTestTask claims a scheduled task of a workflow run and completes it.
+func runTestTask(t *testing.T, api *API, workflowRunID, workflowTaskID string, ok bool, output map[string]interface{}) {
+	t.Helper()
+	taskID, attemptID := claimTestTask(t, api, workflowRunID, workflowTaskID)
+	err := api.CompleteTask(TaskResult{
+		ID:        taskID,
+		AttemptID: attemptID,
+		OK:        ok,
+		Output:    output,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testTaskStates returns the states of the tasks of a workflow run:
+// scheduled, succeeded, failed or skipped.
+func testTaskStates(t *testing.T, api *API, workflowRunID string) map[string]string {
+	t.Helper()
+	rows, err := api.db.Query(`select workflow_task_id, case
+		when skipped then 'skipped'
+		when completed_at is null then 'scheduled'
+		when success then 'succeeded'
+		else 'failed' end
+	from tasks where workflow_run_id = $1`, workflowRunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	states := map[string]string{}
+	for rows.Next() {
+		id, state := "", ""
+		err = rows.Scan(&id, &state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		states[id] = state
+	}
+	return states
+}
+
+// testRunState returns whether a workflow run is running, succeeded or
+// failed.
+func testRunState(t *testing.T, api *API, workflowRunID string) string {
+	t.Helper()
+	var success sql.NullBool
+	completed := false
+	err := api.db.QueryRow("select completed_at is not null, success from workflow_runs where id = $1", workflowRunID).
+		Scan(&completed, &success)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case !completed:
+		return "running"
+	case success.Bool:
+		return "succeeded"
+	default:
+		return "failed"
+	}
+}
+
+// testTaskInput returns the input of a task of a workflow run.
+func testTaskInput(t *testing.T, api *API, workflowRunID, workflowTaskID string) map[string]interface{} {
+	t.Helper()
+	var text []byte
+	err := api.db.QueryRow("select input from tasks where workflow_run_id = $1 and workflow_task_id = $2",
+		workflowRunID, workflowTaskID).Scan(&text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := map[string]interface{}{}
+	err = json.Unmarshal(text, &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input
+}
+
+// testRequest sends a request to the API, with body encoded as JSON if it
+// isn't nil, and decodes the response into v, if it isn't nil. It returns
+// the status code.
+func testRequest(t *testing.T, api *API, method, path string, body, v interface{}) int {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if body != nil {
+		err := json.NewEncoder(buf).Encode(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := httptest.NewRecorder()
+	api.router.ServeHTTP(rec, httptest.NewRequest(method, path, buf))
+	if v != nil {
+		resp := struct {
+			Response interface{} `json:"response"`
+		}{v}
+		err := json.NewDecoder(rec.Body).Decode(&resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code
+}
+
+// loadTestConfig replaces the config of the API.
+func loadTestConfig(t *testing.T, api *API, conf string) {
+	t.Helper()
+	err := os.WriteFile(api.configPath, []byte(conf), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = api.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testQueryDataset runs a query on the latest version of a dataset.
+func testQueryDataset(t *testing.T, api *API, datasetID, query string) [][]interface{} {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file:"+api.datasetFilename(datasetID, "")+"?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := [][]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		err = rows.Scan(pointers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, values)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
 
@@ -11,7 +12,7 @@ func (api *API) getWorkflowRuns(_ http.ResponseWriter, r *http.Request) Response
 	vars := mux.Vars(r)
 	workflowID := vars["workflow_id"]
 
-	rows, err := api.db.Query("SELECT id, config_hash, started_at, completed_at, success FROM workflow_runs WHERE workflow_id = $1",
+	rows, err := api.db.Query("SELECT id, config_hash, started_at, completed_at, success, input, dataset_versions FROM workflow_runs WHERE workflow_id = $1",
 		workflowID)
 	if err != nil {
 		log.Println(err)
@@ -26,7 +27,12 @@ func (api *API) getWorkflowRuns(_ http.ResponseWriter, r *http.Request) Response
 		run := WorkflowRun{
 			WorkflowID: workflowID,
 		}
-		err = rows.Scan(&run.ID, &run.ConfigHash, &run.StartedAt, &run.CompletedAt, &run.Success)
+		input := ""
+		datasetVersions := ""
+		err = rows.Scan(&run.ID, &run.ConfigHash, &run.StartedAt, &run.CompletedAt, &run.Success, &input, &datasetVersions)
+		if err == nil {
+			err = json.Unmarshal([]byte(datasetVersions), &run.DatasetVersions)
+		}
 		if err != nil {
 			log.Println(err)
 			return Response{
@@ -34,6 +40,7 @@ func (api *API) getWorkflowRuns(_ http.ResponseWriter, r *http.Request) Response
 				Error:  err.Error(),
 			}
 		}
+		run.Input = json.RawMessage(input)
 		runs = append(runs, run)
 	}
 	return Response{
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+)
+
+// postWorkflowRunRerun starts a new run of a workflow with the same input,
+// config and dataset versions as an earlier run.
+func (api *API) postWorkflowRunRerun(_ http.ResponseWriter, r *http.Request) Response {
+	vars := mux.Vars(r)
+	workflowID := vars["workflow_id"]
+	workflowRunID := vars["workflow_run_id"]
+
+	var input []byte
+	hash := ""
+	err := api.db.QueryRow("SELECT input, config_hash FROM workflow_runs WHERE id = $1 AND workflow_id = $2", workflowRunID, workflowID).
+		Scan(&input, &hash)
+	if err == sql.ErrNoRows {
+		return Response{
+			Status: http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+	workflowInput := map[string]interface{}{}
+	err = json.Unmarshal(input, &workflowInput)
+	if err != nil {
+		log.Println(err)
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+	datasetVersions, err := api.workflowRunDatasetVersions(workflowRunID)
+	if err != nil {
+		log.Println(err)
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+	for dataset, version := range datasetVersions {
+		if _, err := os.Stat(api.datasetFilename(dataset, version)); err != nil {
+			return Response{
+				Status: http.StatusConflict,
+				Error:  fmt.Sprintf("version %s of dataset %s is no longer available", version, dataset),
+			}
+		}
+	}
+
+	// The rerun uses the same config as the run, since the dataset
+	// versions belong to its tasks.
+	newWorkflowRunID, err := api.startWorkflowRun(hash, workflowID, workflowInput, datasetVersions)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Response{
+			Status: http.StatusNotFound,
+			Error:  fmt.Sprintf("workflow %s isn't in the config of the run", workflowID),
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+	return Response{
+		Response: WorkflowRun{
+			ID:              newWorkflowRunID,
+			ConfigHash:      hash,
+			WorkflowID:      workflowID,
+			Input:           input,
+			DatasetVersions: datasetVersions,
+		},
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRerunUsesConfigOfRun(t *testing.T) {
+	api := newTestAPI(t, `
+workflows:
+  - id: report
+    tasks:
+      extract:
+        image: alpine
+        next: load
+      load:
+        image: alpine
+`)
+	hash, err := api.LatestConfigHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := startTestRun(t, api, "report", map[string]interface{}{"day": "2024-01-02"})
+
+	err = os.WriteFile(api.configPath, []byte(`
+workflows:
+  - id: report
+    tasks:
+      summarize:
+        image: alpine
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = api.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rerun := WorkflowRun{}
+	status := testRequest(t, api, http.MethodPost, "/api/workflows/report/runs/"+run+"/rerun", nil, &rerun)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if rerun.ConfigHash != hash {
+		t.Errorf("expected config %s, got %s", hash, rerun.ConfigHash)
+	}
+	expected := map[string]string{"extract": "scheduled"}
+	if states := testTaskStates(t, api, rerun.ID); !reflect.DeepEqual(states, expected) {
+		t.Errorf("expected %v, got %v", expected, states)
+	}
+	if input := testTaskInput(t, api, rerun.ID, "extract"); input["day"] != "2024-01-02" {
+		t.Errorf("expected the input of the run, got %v", input)
+	}
+}
+
+func TestRerunNotFound(t *testing.T) {
+	api := newTestAPI(t, `
+workflows:
+  - id: report
+    tasks:
+      extract:
+        image: alpine
+`)
+	status := testRequest(t, api, http.MethodPost, "/api/workflows/report/runs/unknown/rerun", nil, nil)
+	if status != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown run, got %d", status)
+	}
+
+	_, err := api.db.Exec(`INSERT INTO workflow_runs (id, config_hash, workflow_id, started_at)
+	VALUES ('old', 'removed', 'report', datetime('now'))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status = testRequest(t, api, http.MethodPost, "/api/workflows/report/runs/old/rerun", nil, nil)
+	if status != http.StatusNotFound {
+		t.Errorf("expected status 404 for a workflow that isn't in the config of the run, got %d", status)
+	}
+}
@@ -15,16 +15,19 @@ func (api *API) getWorkflowRunTasks(_ http.ResponseWriter, r *http.Request) Resp
 	rows, err := api.db.Query(`SELECT
 		id,
 		workflow_task_id,
+		type,
 		input,
 		output,
 		created_at,
+		run_at,
 		started_at,
 		timeout_at,
 		completed_at,
 		attempts_left,
 		stdout,
 		stderr,
-		success
+		success,
+		skipped
 	FROM tasks WHERE workflow_run_id = $1`,
 		workflowRunID)
 	if err != nil {
@@ -41,9 +44,9 @@ func (api *API) getWorkflowRunTasks(_ http.ResponseWriter, r *http.Request) Resp
 			WorkflowRunID: workflowRunID,
 		}
 		output := ""
-		err = rows.Scan(&run.ID, &run.WorkflowTaskID, &run.Input, &output,
-			&run.CreatedAt, &run.StartedAt, &run.TimeoutAt, &run.CompletedAt, &run.AttemptsLeft,
-			&run.Stdout, &run.Stderr, &run.Success)
+		err = rows.Scan(&run.ID, &run.WorkflowTaskID, &run.Type, &run.Input, &output,
+			&run.CreatedAt, &run.RunAt, &run.StartedAt, &run.TimeoutAt, &run.CompletedAt, &run.AttemptsLeft,
+			&run.Stdout, &run.Stderr, &run.Success, &run.Skipped)
 		if err != nil {
 			log.Println(err)
 			return Response{
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (api *API) getWorkflowRunTaskAttempts(_ http.ResponseWriter, r *http.Request) Response {
+	vars := mux.Vars(r)
+	workflowRunID := vars["workflow_run_id"]
+	taskID := vars["task_id"]
+
+	rows, err := api.db.Query(`SELECT
+		task_attempts.id,
+		runner,
+		task_attempts.started_at,
+		task_attempts.completed_at,
+		task_attempts.success,
+		exit_code,
+		task_attempts.stdout,
+		task_attempts.stderr,
+		COALESCE(task_attempts.output, '{}')
+	FROM task_attempts JOIN tasks ON tasks.id = task_attempts.task_id
+	WHERE tasks.workflow_run_id = $1 AND task_attempts.task_id = $2
+	ORDER BY task_attempts.started_at, task_attempts.rowid`,
+		workflowRunID, taskID)
+	if err != nil {
+		log.Println(err)
+		return Response{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}
+	}
+	defer rows.Close()
+	attempts := []TaskAttempt{}
+	for rows.Next() {
+		attempt := TaskAttempt{
+			TaskID: taskID,
+		}
+		output := ""
+		err = rows.Scan(&attempt.ID, &attempt.Runner, &attempt.StartedAt, &attempt.CompletedAt, &attempt.Success,
+			&attempt.ExitCode, &attempt.Stdout, &attempt.Stderr, &output)
+		if err != nil {
+			log.Println(err)
+			return Response{
+				Status: http.StatusInternalServerError,
+				Error:  err.Error(),
+			}
+		}
+		attempt.Output = json.RawMessage(output)
+		attempts = append(attempts, attempt)
+	}
+	return Response{
+		Response: attempts,
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTaskAttempts(t *testing.T) {
+	api := newTestAPI(t, `
+workflows:
+  - id: report
+    tasks:
+      load:
+        image: alpine
+        retries: 2
+        timeout: 10m
+`)
+	run := startTestRun(t, api, "report", nil)
+	poll := func(runner string) Task {
+		t.Helper()
+		task := Task{}
+		status := testRequest(t, api, http.MethodGet, "/api/tasks/poll?runner="+runner, nil, &task)
+		if status != http.StatusOK || task.ID == "" || task.AttemptID == "" {
+			t.Fatalf("expected a task, got %d %+v", status, task)
+		}
+		return task
+	}
+
+	first := poll("first")
+	// The first attempt times out, so the task is claimed again.
+	_, err := api.db.Exec("update tasks set timeout_at = datetime('now', '-1 minute') where id = $1", first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := poll("second")
+	if second.ID != first.ID || second.AttemptID == first.AttemptID {
+		t.Fatalf("expected a new attempt of task %s, got %+v", first.ID, second)
+	}
+
+	// The late result of the first attempt is dropped.
+	status := testRequest(t, api, http.MethodPost, "/api/tasks/result", TaskResult{
+		ID:        first.ID,
+		AttemptID: first.AttemptID,
+		OK:        false,
+	}, nil)
+	if status != http.StatusConflict {
+		t.Errorf("expected status 409 for a stale attempt, got %d", status)
+	}
+	if state := testTaskStates(t, api, run)["load"]; state != "scheduled" {
+		t.Errorf("expected the task to still be running, got %s", state)
+	}
+
+	status = testRequest(t, api, http.MethodPost, "/api/tasks/result", TaskResult{
+		ID:        second.ID,
+		AttemptID: second.AttemptID,
+		OK:        true,
+		Output:    map[string]interface{}{"rows": 3},
+	}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if state := testRunState(t, api, run); state != "succeeded" {
+		t.Errorf("expected the run to succeed, got %s", state)
+	}
+
+	attempts := []TaskAttempt{}
+	status = testRequest(t, api, http.MethodGet, "/api/workflows/report/runs/"+run+"/tasks/"+first.ID+"/attempts", nil, &attempts)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %+v", attempts)
+	}
+	timedOut, succeeded := attempts[0], attempts[1]
+	if timedOut.ID != first.AttemptID || timedOut.Runner != "first" || timedOut.Success == nil || *timedOut.Success ||
+		timedOut.Stderr == nil || *timedOut.Stderr != "timed out" {
+		t.Errorf("expected the first attempt to have timed out, got %+v", timedOut)
+	}
+	if succeeded.ID != second.AttemptID || succeeded.Runner != "second" || succeeded.Success == nil || !*succeeded.Success ||
+		string(succeeded.Output) != `{"rows":3}` {
+		t.Errorf("expected the second attempt to succeed, got %+v", succeeded)
+	}
+
+	status = testRequest(t, api, http.MethodGet, "/api/workflows/report/runs/other/tasks/"+first.ID+"/attempts", nil, &attempts)
+	if status != http.StatusOK || len(attempts) != 0 {
+		t.Errorf("expected no attempts for another run, got %d %+v", status, attempts)
+	}
+}
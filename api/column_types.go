@@ -0,0 +1,129 @@
+package api
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+}
+
+// parseValue parses text as a value of a SQLite column type. Surrounding
+// whitespace is ignored and empty text is NULL, except in TEXT columns.
+func parseValue(s string, sqlType string) (interface{}, bool) {
+	if sqlType == "TEXT" {
+		return s, true
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, true
+	}
+	switch sqlType {
+	case "INTEGER":
+		// Numbers with leading zeros, like postal codes, are text.
+		digits := strings.TrimPrefix(s, "-")
+		if digits == "" || (len(digits) > 1 && digits[0] == '0') || digits[0] < '0' || digits[0] > '9' {
+			return nil, false
+		}
+		i, err := strconv.ParseInt(s, 10, 64)
+		return i, err == nil
+	case "REAL":
+		digits := strings.TrimPrefix(s, "-")
+		if digits == "" || (len(digits) > 1 && digits[0] == '0' && digits[1] != '.') ||
+			strings.ContainsAny(digits, "xXpP_") || (digits[0] != '.' && (digits[0] < '0' || digits[0] > '9')) {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	case "BOOLEAN":
+		switch strings.ToLower(s) {
+		case "true", "1":
+			return true, true
+		case "false", "0":
+			return false, true
+		}
+		return nil, false
+	case "DATE":
+		_, err := time.Parse("2006-01-02", s)
+		return s, err == nil
+	case "TIMESTAMP":
+		for _, layout := range timestampLayouts {
+			if _, err := time.Parse(layout, s); err == nil {
+				return s, true
+			}
+		}
+		return nil, false
+	default:
+		return s, true
+	}
+}
+
+// inferredTypes are the types that can be inferred from text, from the
+// most to the least specific.
+var inferredTypes = []string{"INTEGER", "REAL", "BOOLEAN", "DATE", "TIMESTAMP"}
+
+// inferColumnType returns the most specific type that all of the
+// non-empty values can be parsed as, or TEXT.
+func inferColumnType(values []string) string {
+	empty := true
+	for _, value := range values {
+		if value != "" {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		return "TEXT"
+	}
+	for _, typ := range inferredTypes {
+		ok := true
+		for _, value := range values {
+			if _, ok = parseValue(value, typ); !ok {
+				break
+			}
+		}
+		if ok {
+			return typ
+		}
+	}
+	return "TEXT"
+}
+
+// sqlColumnType maps the type of a column of a database to a SQLite type.
+// Types that aren't known are left untyped. Exact decimals are kept as
+// text, since REAL would round amounts and large numbers; their columns
+// can be typed as real in the config of the data source.
+func sqlColumnType(columnType *sql.ColumnType) string {
+	name := strings.ToUpper(columnType.DatabaseTypeName())
+	name = strings.TrimSpace(strings.TrimPrefix(name, "UNSIGNED"))
+	if i := strings.IndexAny(name, "( "); i >= 0 {
+		name = name[:i]
+	}
+	switch name {
+	case "INT", "INT2", "INT4", "INT8", "INTEGER", "SMALLINT", "MEDIUMINT", "BIGINT", "TINYINT", "YEAR",
+		"SERIAL", "SMALLSERIAL", "BIGSERIAL":
+		return "INTEGER"
+	case "REAL", "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE":
+		return "REAL"
+	case "BOOL", "BOOLEAN":
+		return "BOOLEAN"
+	case "DATE":
+		return "DATE"
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATETIME":
+		return "TIMESTAMP"
+	case "NUMERIC", "DECIMAL", "MONEY",
+		"TEXT", "VARCHAR", "CHAR", "BPCHAR", "NCHAR", "NVARCHAR", "CHARACTER", "NAME", "CITEXT", "UUID",
+		"JSON", "JSONB", "XML", "ENUM", "SET", "TIME", "TIMETZ", "INTERVAL", "TINYTEXT", "MEDIUMTEXT", "LONGTEXT":
+		return "TEXT"
+	}
+	if isBinaryColumn(columnType) {
+		return "BLOB"
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package api
+
+import "testing"
+
+func TestParseValue(t *testing.T) {
+	for _, tc := range []struct {
+		s        string
+		sqlType  string
+		expected interface{}
+		ok       bool
+	}{
+		{" padded ", "TEXT", " padded ", true},
+		{"", "TEXT", "", true},
+		{"", "INTEGER", nil, true},
+		{"  ", "REAL", nil, true},
+		{"42", "INTEGER", int64(42), true},
+		{" -7 ", "INTEGER", int64(-7), true},
+		{"0", "INTEGER", int64(0), true},
+		{"007", "INTEGER", nil, false},
+		{"+7", "INTEGER", nil, false},
+		{"0x1f", "INTEGER", nil, false},
+		{"1_000", "INTEGER", nil, false},
+		{"-", "INTEGER", nil, false},
+		{"1.5", "INTEGER", nil, false},
+		{"99999999999999999999", "INTEGER", nil, false},
+		{"1.5", "REAL", 1.5, true},
+		{"0.25", "REAL", 0.25, true},
+		{".5", "REAL", 0.5, true},
+		{"-2e3", "REAL", -2000.0, true},
+		{"10", "REAL", 10.0, true},
+		{"01.5", "REAL", nil, false},
+		{"0x1p-2", "REAL", nil, false},
+		{"1_000.5", "REAL", nil, false},
+		{"Inf", "REAL", nil, false},
+		{"NaN", "REAL", nil, false},
+		{"true", "BOOLEAN", true, true},
+		{"FALSE", "BOOLEAN", false, true},
+		{"1", "BOOLEAN", true, true},
+		{"0", "BOOLEAN", false, true},
+		{"yes", "BOOLEAN", nil, false},
+		{"2024-02-29", "DATE", "2024-02-29", true},
+		{"2023-02-29", "DATE", "2023-02-29", false},
+		{"2024-02-29T10:00:00Z", "DATE", "2024-02-29T10:00:00Z", false},
+		{"2024-02-29T10:00:00Z", "TIMESTAMP", "2024-02-29T10:00:00Z", true},
+		{"2024-02-29T10:00:00.123+01:00", "TIMESTAMP", "2024-02-29T10:00:00.123+01:00", true},
+		{"2024-02-29 10:00:00", "TIMESTAMP", "2024-02-29 10:00:00", true},
+		{"2024-02-29", "TIMESTAMP", nil, false},
+	} {
+		value, ok := parseValue(tc.s, tc.sqlType)
+		if ok != tc.ok || (ok && value != tc.expected) {
+			t.Errorf("%s `%s`: expected %v (%v), got %v (%v)", tc.sqlType, tc.s, tc.expected, tc.ok, value, ok)
+		}
+	}
+}
+
+func TestInferColumnType(t *testing.T) {
+	for _, tc := range []struct {
+		values   []string
+		expected string
+	}{
+		{[]string{"1", "2", "", "-3"}, "INTEGER"},
+		{[]string{"1", "0"}, "INTEGER"},
+		{[]string{"1", "2.5"}, "REAL"},
+		{[]string{"true", "false", ""}, "BOOLEAN"},
+		{[]string{"true", "1"}, "BOOLEAN"},
+		{[]string{"2024-01-02", ""}, "DATE"},
+		{[]string{"2024-01-02", "2024-01-02 10:00:00"}, "TEXT"},
+		{[]string{"2024-01-02T10:00:00Z"}, "TIMESTAMP"},
+		{[]string{"02134", "10001"}, "TEXT"},
+		{[]string{"", ""}, "TEXT"},
+		{[]string{}, "TEXT"},
+		{[]string{"1", "one"}, "TEXT"},
+	} {
+		if typ := inferColumnType(tc.values); typ != tc.expected {
+			t.Errorf("%q: expected %s, got %s", tc.values, tc.expected, typ)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/crossjoin-io/crossjoin/config"
 )
@@ -39,7 +40,7 @@ func (api *API) LoadConfig() error {
 		basePath := parsedURL
 		basePath.Path = path.Dir(basePath.Path)
 		log.Println("using GitHub file", api.configPath)
-		configFileContent, err := api.fetchGitHubFile(api.configPath)
+		configFileContent, err := fetchGitHubFile(api.configPath)
 		if err != nil {
 			return fmt.Errorf("read file from github: %w", err)
 		}
@@ -49,6 +50,15 @@ func (api *API) LoadConfig() error {
 		}
 	}
 
+	for _, dataset := range conf.Datasets {
+		if dataset.Transform != "" || len(dataset.Select) > 0 {
+			err := validateDatasetQuery(dataset)
+			if err != nil {
+				return fmt.Errorf("parse config: dataset `%s`: %w", dataset.ID, err)
+			}
+		}
+	}
+
 	hash := conf.Hash()
 	var x int
 	err := api.db.QueryRow("SELECT 1 FROM configs WHERE hash = $1", hash).Scan(&x)
@@ -57,8 +67,9 @@ func (api *API) LoadConfig() error {
 			return fmt.Errorf("query config: %w", err)
 		}
 
-		// Config doesn't exist.
-		_, err = api.db.Exec("INSERT INTO configs (loaded_at, hash, config) VALUES (datetime('now'), $1, $2)", hash, conf.JSON())
+		// Config doesn't exist. Configs can be reloaded within a
+		// second, so loaded_at has millisecond resolution.
+		_, err = api.db.Exec("INSERT INTO configs (loaded_at, hash, config) VALUES (strftime('%Y-%m-%d %H:%M:%f', 'now'), $1, $2)", hash, conf.JSON())
 		if err != nil {
 			return fmt.Errorf("store config: %w", err)
 		}
@@ -85,12 +96,60 @@ func (api *API) LoadConfig() error {
 	}
 
 	// Config already exists with the hash.
-	_, err = api.db.Exec("UPDATE configs SET loaded_at = datetime('now') WHERE hash = $1", hash)
+	_, err = api.db.Exec("UPDATE configs SET loaded_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE hash = $1", hash)
 	return err
 }
 
 func (api *API) LatestConfigHash() (string, error) {
 	hash := ""
-	err := api.db.QueryRow("SELECT hash FROM configs ORDER BY loaded_at DESC LIMIT 1").Scan(&hash)
+	err := api.db.QueryRow("SELECT hash FROM configs ORDER BY loaded_at DESC, rowid DESC LIMIT 1").Scan(&hash)
 	return hash, err
 }
+
+// validateDatasetQuery checks the query of a dataset with SQLite, against
+// empty tables named after the data sources. The columns of the data
+// sources aren't known until they're read, so errors about columns are
+// only reported when the dataset is refreshed.
+func validateDatasetQuery(dataset config.Dataset) error {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	// Every connection has its own in-memory database.
+	db.SetMaxOpenConns(1)
+
+	tables := []string{dataset.DataSource.ID}
+	for _, join := range dataset.Joins {
+		tables = append(tables, join.DataSource.ID)
+	}
+	for _, table := range tables {
+		_, err = db.Exec(fmt.Sprintf("CREATE TABLE %s (_)", quoteIdentifier(table)))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only a single SELECT query is valid in a subquery.
+	stmt, err := db.Prepare("SELECT * FROM (" + dataset.Query() + "\n)")
+	if err != nil {
+		for _, prefix := range queryErrorPrefixes {
+			if strings.HasPrefix(err.Error(), prefix) {
+				return fmt.Errorf("invalid query: %w", err)
+			}
+		}
+		return nil
+	}
+	return stmt.Close()
+}
+
+// queryErrorPrefixes are the SQLite errors that don't depend on the
+// columns of the tables.
+var queryErrorPrefixes = []string{
+	"near ",
+	"unrecognized token",
+	"incomplete input",
+	"no such table",
+	"no such function",
+	"wrong number of arguments",
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/crossjoin-io/crossjoin/config"
+)
+
+func TestValidateDatasetQuery(t *testing.T) {
+	for _, tc := range []struct {
+		dataset config.Dataset
+		valid   bool
+	}{
+		{config.Dataset{Select: []string{`orders."Order ID"`, "returns.Returned AS returned"}}, true},
+		{config.Dataset{Transform: "SELECT region, SUM(amount) AS total FROM orders LEFT JOIN returns USING (id) GROUP BY region;"}, true},
+		{config.Dataset{Transform: "WITH big AS (SELECT * FROM orders WHERE amount > 100)\nSELECT * FROM big -- large orders"}, true},
+		// Columns aren't known until the data sources are read.
+		{config.Dataset{Transform: "SELECT nope FROM orders"}, true},
+		{config.Dataset{Transform: "SELECT * FROM orders WHERE"}, false},
+		{config.Dataset{Transform: "SELECT * FROM orders; DROP TABLE returns"}, false},
+		{config.Dataset{Transform: "DELETE FROM orders"}, false},
+		{config.Dataset{Transform: "SELECT * FROM refunds"}, false},
+		{config.Dataset{Transform: "SELECT nosuchfunction(1) FROM orders"}, false},
+		{config.Dataset{Select: []string{"amount +"}}, false},
+	} {
+		dataset := tc.dataset
+		dataset.ID = "orders_and_returns"
+		dataset.DataSource = &config.DataSource{ID: "orders"}
+		dataset.Joins = []config.Join{{
+			Type:       "LEFT JOIN",
+			Columns:    []config.JoinColumns{{LeftColumn: "id", RightColumn: "id"}},
+			DataSource: &config.DataSource{ID: "returns"},
+		}}
+		err := validateDatasetQuery(dataset)
+		if tc.valid && err != nil {
+			t.Errorf("%q: %s", dataset.Query(), err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%q: expected an error", dataset.Query())
+		}
+	}
+}
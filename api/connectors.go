@@ -0,0 +1,37 @@
+package api
+
+import (
+	"github.com/crossjoin-io/crossjoin/config"
+	"github.com/crossjoin-io/crossjoin/connector"
+)
+
+func init() {
+	for _, format := range []string{"csv", "json", "jsonl", "parquet", "xlsx"} {
+		registerBuiltin(format, fileConnector{builtinValidator(format), format})
+	}
+	registerBuiltin("s3", s3Connector{builtinValidator("s3")})
+	registerBuiltin("http", httpConnector{builtinValidator("http")})
+	for _, name := range []string{"postgres", "mysql", "sqlite"} {
+		registerBuiltin(name, sqlConnector{builtinValidator(name)})
+	}
+}
+
+// registerBuiltin registers the data connector of a built-in type, which
+// can only fail if another package registered the type first.
+func registerBuiltin(name string, c connector.DataConnector) {
+	err := connector.Register(name, c)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// builtinValidator returns the validator of a built-in data connection
+// type, which is part of the config package so that configs can be
+// validated without the api package.
+func builtinValidator(name string) config.DataConnectionValidator {
+	validator, ok := config.LookupDataConnectionType(name)
+	if !ok {
+		panic("missing validator of data connection type " + name)
+	}
+	return validator
+}
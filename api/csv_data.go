@@ -0,0 +1,231 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/crossjoin-io/crossjoin/config"
+	"github.com/crossjoin-io/crossjoin/connector"
+)
+
+// sourceFileColumn holds the name of the file each row was read from.
+const sourceFileColumn = "_source_file"
+
+// csvSampleRows is the number of rows that the types of CSV columns are
+// inferred from.
+const csvSampleRows = 1000
+
+// csvRows reads the rows of CSV files. Every file needs to have the same
+// columns, but they can be in a different order. The types of the
+// columns are inferred from the first rows; later values that don't have
+// the type of their column are kept as text. Columns whose types are
+// overridden aren't inferred.
+type csvRows struct {
+	files             []dataFile
+	includeSourceFile bool
+	columns           []string
+	types             []string
+	sample            [][]string
+
+	// The file being read, and how its fields map to the columns.
+	index      int
+	file       dataFileReader
+	reader     *csv.Reader
+	order      []int
+	sourceFile string
+
+	record []string
+	values []interface{}
+	err    error
+}
+
+func openCSV(files []dataFile, includeSourceFile bool, columnTypes map[string]string) (*csvRows, error) {
+	rows := &csvRows{
+		files:             files,
+		includeSourceFile: includeSourceFile,
+	}
+	err := rows.openFile(0)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(rows.sample) < csvSampleRows && rows.read() {
+		rows.sample = append(rows.sample, rows.record)
+	}
+	if rows.err != nil {
+		rows.Close()
+		return nil, rows.err
+	}
+	rows.types = make([]string, len(rows.columns))
+	for i, column := range rows.columns {
+		if typ, ok := columnTypes[column]; ok {
+			rows.types[i] = config.ColumnTypes[typ]
+			continue
+		}
+		values := make([]string, len(rows.sample))
+		for j, record := range rows.sample {
+			values[j] = record[i]
+		}
+		rows.types[i] = inferColumnType(values)
+	}
+	return rows, nil
+}
+
+func (rows *csvRows) openFile(index int) error {
+	file := rows.files[index]
+	f, _, err := file.Open()
+	if err != nil {
+		return err
+	}
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("read header of `%s`: %w", file.Name, err)
+	}
+	if rows.columns == nil {
+		rows.columns = header
+	}
+	order, err := csvColumnOrder(rows.columns, header)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("header of `%s` doesn't match `%s`: %w", file.Name, rows.files[0].Name, err)
+	}
+
+	rows.index = index
+	rows.file = f
+	rows.reader = r
+	rows.order = order
+	rows.sourceFile = path.Base(filepath.ToSlash(file.Name))
+	return nil
+}
+
+func (rows *csvRows) Columns() []connector.Column {
+	columns := make([]connector.Column, len(rows.columns), len(rows.columns)+1)
+	for i, name := range rows.columns {
+		columns[i] = connector.Column{Name: name, Type: rows.types[i]}
+	}
+	if rows.includeSourceFile {
+		columns = append(columns, connector.Column{Name: sourceFileColumn, Type: "TEXT"})
+	}
+	return columns
+}
+
+func (rows *csvRows) Next() bool {
+	var record []string
+	if len(rows.sample) > 0 {
+		record, rows.sample = rows.sample[0], rows.sample[1:]
+	} else if rows.read() {
+		record = rows.record
+	} else {
+		return false
+	}
+
+	values := make([]interface{}, len(record))
+	for i, field := range record {
+		if i >= len(rows.types) {
+			values[i] = field
+			continue
+		}
+		value, ok := parseValue(field, rows.types[i])
+		if !ok {
+			value = field
+		}
+		values[i] = value
+	}
+	rows.values = values
+	return true
+}
+
+// read reads the next record, with the fields in the order of the
+// columns and followed by the source file if it's included.
+func (rows *csvRows) read() bool {
+	for rows.reader != nil {
+		record, err := rows.reader.Read()
+		if err == io.EOF {
+			rows.file.Close()
+			rows.file, rows.reader = nil, nil
+			if rows.index+1 < len(rows.files) {
+				rows.err = rows.openFile(rows.index + 1)
+			}
+			continue
+		}
+		if err != nil {
+			rows.err = fmt.Errorf("read `%s`: %w", rows.files[rows.index].Name, err)
+			return false
+		}
+		fields := make([]string, len(rows.columns), len(rows.columns)+1)
+		for i, field := range record {
+			fields[rows.order[i]] = field
+		}
+		if rows.includeSourceFile {
+			fields = append(fields, rows.sourceFile)
+		}
+		rows.record = fields
+		return true
+	}
+	return false
+}
+
+func (rows *csvRows) Values() []interface{} { return rows.values }
+
+func (rows *csvRows) Err() error { return rows.err }
+
+func (rows *csvRows) Close() error {
+	if rows.file != nil {
+		rows.file.Close()
+		rows.file, rows.reader = nil, nil
+	}
+	return nil
+}
+
+func csvColumnOrder(columns, header []string) ([]int, error) {
+	indexes := map[string]int{}
+	for i, column := range columns {
+		indexes[column] = i
+	}
+
+	order := make([]int, len(header))
+	seen := map[string]bool{}
+	extra := []string{}
+	for i, column := range header {
+		index, ok := indexes[column]
+		if !ok || seen[column] {
+			extra = append(extra, column)
+			continue
+		}
+		seen[column] = true
+		order[i] = index
+	}
+	missing := []string{}
+	for _, column := range columns {
+		if !seen[column] {
+			missing = append(missing, column)
+		}
+	}
+
+	problems := []string{}
+	if len(missing) > 0 {
+		problems = append(problems, "missing columns "+quoteColumns(missing))
+	}
+	if len(extra) > 0 {
+		problems = append(problems, "unexpected columns "+quoteColumns(extra))
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, " and "))
+	}
+	return order, nil
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = "`" + column + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
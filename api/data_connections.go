@@ -1,12 +1,19 @@
 package api
 
 import (
+	"database/sql"
+
 	"github.com/crossjoin-io/crossjoin/config"
+	"gopkg.in/yaml.v2"
 )
 
 func (api *API) StoreDataConnection(hash string, connection config.DataConnection) error {
-	_, err := api.db.Exec("REPLACE INTO data_connections (config_hash, id, type, path, connection_string) VALUES ($1, $2, $3, $4, $5)",
-		hash, connection.ID, connection.Type, connection.Path, connection.ConnectionString,
+	marshaledConnection, err := yaml.Marshal(connection)
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec("REPLACE INTO data_connections (config_hash, id, type, path, connection_string, text) VALUES ($1, $2, $3, $4, $5, $6)",
+		hash, connection.ID, connection.Type, connection.Path, connection.ConnectionString, marshaledConnection,
 	)
 	return err
 }
@@ -15,11 +22,20 @@ func (api *API) ReadDataConnection(hash, id string) (*config.DataConnection, err
 	conn := &config.DataConnection{
 		ID: id,
 	}
-	err := api.db.QueryRow("SELECT type, path, connection_string FROM data_connections WHERE config_hash = $1 AND id = $2", hash, id).
-		Scan(&conn.Type, &conn.Path, &conn.ConnectionString)
+	var text sql.NullString
+	err := api.db.QueryRow("SELECT type, path, connection_string, text FROM data_connections WHERE config_hash = $1 AND id = $2", hash, id).
+		Scan(&conn.Type, &conn.Path, &conn.ConnectionString, &text)
 	if err != nil {
 		return nil, err
 	}
+	// Connections stored before the text column was added only have
+	// the type, path and connection string.
+	if text.Valid {
+		err = yaml.Unmarshal([]byte(text.String), conn)
+		if err != nil {
+			return nil, err
+		}
+	}
 	conn.ExpandConnectionString()
 	return conn, nil
 }
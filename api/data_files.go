@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/crossjoin-io/crossjoin/config"
+	"github.com/crossjoin-io/crossjoin/connector"
+)
+
+// dataFile is a file read by a file-based data connection, either from
+// a local path, a URL or object storage.
+type dataFile struct {
+	Name string
+	// Open returns a reader of the file and its size.
+	Open func() (dataFileReader, int64, error)
+}
+
+type dataFileReader interface {
+	io.Reader
+	io.ReaderAt
+	io.Closer
+}
+
+type bytesFile struct {
+	*bytes.Reader
+}
+
+func (bytesFile) Close() error { return nil }
+
+// matchFiles returns the files of a path, which can be a single file, a
+// glob or a directory. Directories are filtered by the extensions, and
+// hidden files and marker files like _SUCCESS are skipped.
+func matchFiles(filePath string, extensions []string) ([]string, error) {
+	isGlob := strings.ContainsAny(filePath, "*?[")
+	urlPath, err := url.Parse(filePath)
+	if err == nil && urlPath.Scheme != "" {
+		if isGlob {
+			return nil, fmt.Errorf("globs are only supported for local paths")
+		}
+		return []string{filePath}, nil
+	}
+
+	if isGlob {
+		files, err := filepath.Glob(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files match `%s`", filePath)
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil || !info.IsDir() {
+		// Let reading the file report any errors.
+		return []string{filePath}, nil
+	}
+	entries, err := os.ReadDir(filePath)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || !hasExtension(name, extensions) {
+			continue
+		}
+		files = append(files, filepath.Join(filePath, name))
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s files found in `%s`", strings.Join(extensions, "/"), filePath)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func hasExtension(name string, extensions []string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// localDataFiles returns the files of a path of a data connection.
+func localDataFiles(filePath string, format string) ([]dataFile, error) {
+	paths, err := matchFiles(filePath, config.FileExtensions[format])
+	if err != nil {
+		return nil, err
+	}
+	files := []dataFile{}
+	for _, p := range paths {
+		p := p
+		files = append(files, dataFile{
+			Name: p,
+			Open: func() (dataFileReader, int64, error) {
+				urlPath, err := url.Parse(p)
+				if err == nil && urlPath.Scheme != "" {
+					r, err := readFile(p)
+					if err != nil {
+						return nil, 0, err
+					}
+					return bytesFile{r}, r.Size(), nil
+				}
+
+				log.Printf("reading file `%s`", p)
+				f, err := os.Open(p)
+				if err != nil {
+					return nil, 0, fmt.Errorf("read file: %w", err)
+				}
+				info, err := f.Stat()
+				if err != nil {
+					f.Close()
+					return nil, 0, err
+				}
+				return f, info.Size(), nil
+			},
+		})
+	}
+	return files, nil
+}
+
+// fileConnector reads data connections of local files, or files on
+// GitHub, of a format.
+type fileConnector struct {
+	config.DataConnectionValidator
+	format string
+}
+
+func (c fileConnector) Open(ctx context.Context, dataConnection *config.DataConnection, dataSource *config.DataSource) (connector.Rows, error) {
+	files, err := localDataFiles(dataConnection.Path, c.format)
+	if err != nil {
+		return nil, err
+	}
+	return openFiles(files, c.format, dataConnection, dataSource)
+}
+
+// openFiles reads the rows of files of a format.
+func openFiles(files []dataFile, format string, dataConnection *config.DataConnection, dataSource *config.DataSource) (connector.Rows, error) {
+	switch format {
+	case "csv":
+		return openCSV(files, dataConnection.IncludeSourceFile, dataSource.Columns)
+	case "parquet":
+		return openParquet(files)
+	case "json", "jsonl":
+		values := []interface{}{}
+		for _, file := range files {
+			f, _, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			fileValues, err := decodeJSONValues(f, format == "jsonl")
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("read `%s`: %w", file.Name, err)
+			}
+			values = append(values, fileValues...)
+		}
+		columns, records, err := jsonRecords(values, dataConnection.FlattenDepth)
+		if err != nil {
+			return nil, err
+		}
+		return connector.NewRows(connector.UntypedColumns(columns), records), nil
+	case "xlsx":
+		if len(files) != 1 {
+			return nil, fmt.Errorf("xlsx data connections need a single file")
+		}
+		f, _, err := files[0].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		columns, records, err := readXLSX(f, dataConnection)
+		if err != nil {
+			return nil, err
+		}
+		return connector.NewRows(connector.UntypedColumns(columns), records), nil
+	default:
+		return nil, fmt.Errorf("unknown format `%s`", format)
+	}
+}
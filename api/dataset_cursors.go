@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/crossjoin-io/crossjoin/config"
+	"github.com/crossjoin-io/crossjoin/connector"
+	"gopkg.in/yaml.v2"
+)
+
+// datasetCursor is the high-water mark of an incremental data source. It's
+// only used while the data source stays the same.
+type datasetCursor struct {
+	SourceHash string
+	Value      interface{}
+}
+
+func (api *API) readDatasetCursors(datasetID string) (map[string]datasetCursor, error) {
+	rows, err := api.db.Query("SELECT data_source_id, source_hash, value FROM dataset_cursors WHERE dataset_id = $1", datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cursors := map[string]datasetCursor{}
+	for rows.Next() {
+		dataSourceID := ""
+		cursor := datasetCursor{}
+		err = rows.Scan(&dataSourceID, &cursor.SourceHash, &cursor.Value)
+		if err != nil {
+			return nil, err
+		}
+		cursors[dataSourceID] = cursor
+	}
+	return cursors, rows.Err()
+}
+
+func (api *API) storeDatasetCursors(datasetID string, cursors map[string]datasetCursor) error {
+	tx, err := api.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("DELETE FROM dataset_cursors WHERE dataset_id = $1", datasetID)
+	if err != nil {
+		return err
+	}
+	for dataSourceID, cursor := range cursors {
+		_, err = tx.Exec("INSERT INTO dataset_cursors (dataset_id, data_source_id, source_hash, value, updated_at) VALUES ($1, $2, $3, $4, datetime('now'))",
+			datasetID, dataSourceID, cursor.SourceHash, cursor.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// dataSourceHash identifies the config of a data source, so that
+// incremental data sources are read from scratch when they change.
+func dataSourceHash(dataSource *config.DataSource) string {
+	b, _ := yaml.Marshal(dataSource)
+	return fmt.Sprintf("%x", sha1.Sum(b))
+}
+
+// incrementalCursors returns the cursors of the data sources of a dataset
+// that can be read incrementally.
+func incrementalCursors(dataset config.Dataset, cursors map[string]datasetCursor) map[string]datasetCursor {
+	dataSources := []*config.DataSource{dataset.DataSource}
+	for _, join := range dataset.Joins {
+		dataSources = append(dataSources, join.DataSource)
+	}
+	incremental := map[string]datasetCursor{}
+	for _, dataSource := range dataSources {
+		cursor, ok := cursors[dataSource.ID]
+		if dataSource.Incremental != nil && ok && cursor.SourceHash == dataSourceHash(dataSource) {
+			incremental[dataSource.ID] = cursor
+		}
+	}
+	return incremental
+}
+
+// fetchIncremental reads the rows of a data source past its cursor and
+// upserts them into its table, or reads all of its rows if there's no
+// cursor. It returns the highest value of the cursor column.
+func fetchIncremental(dest *sql.DB, dataConnection *config.DataConnection, dataSource *config.DataSource, cursor *datasetCursor) (*datasetCursor, error) {
+	c, ok := connector.Lookup(dataConnection.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown data connection type `%s`", dataConnection.Type)
+	}
+	ic, ok := c.(connector.IncrementalConnector)
+	if !ok {
+		return nil, fmt.Errorf("data connection type `%s` doesn't support incremental refreshes", dataConnection.Type)
+	}
+	incremental := dataSource.Incremental
+
+	var value interface{} = incremental.InitialValue
+	if cursor != nil {
+		value = cursor.Value
+	}
+	rows, err := ic.OpenIncremental(context.Background(), dataConnection, dataSource, value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if cursor == nil {
+		primaryKey := make([]string, len(incremental.PrimaryKey))
+		for i, column := range incremental.PrimaryKey {
+			primaryKey[i] = quoteIdentifier(column)
+		}
+		err = copyRows(dest, dataSource.ID, dataSource, rows)
+		if err != nil {
+			return nil, err
+		}
+		_, err = dest.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)",
+			quoteIdentifier("_primary_key_"+dataSource.ID), dataSource.ID, strings.Join(primaryKey, ",")))
+		if err != nil {
+			return nil, fmt.Errorf("create primary key of `%s`: %w", dataSource.ID, err)
+		}
+	} else {
+		// Data source IDs start with a letter, so the staging table
+		// can't have the name of one.
+		staging := "_incremental_" + dataSource.ID
+		_, err = dest.Exec("DROP TABLE IF EXISTS " + staging)
+		if err != nil {
+			return nil, err
+		}
+		err = copyRows(dest, staging, dataSource, rows)
+		if err != nil {
+			return nil, err
+		}
+		columns := []string{}
+		for _, column := range rows.Columns() {
+			columns = append(columns, quoteIdentifier(column.Name))
+		}
+		result, err := dest.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) SELECT %s FROM %s",
+			dataSource.ID, strings.Join(columns, ","), strings.Join(columns, ","), staging))
+		if err != nil {
+			return nil, fmt.Errorf("upsert rows of `%s`: %w", dataSource.ID, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			log.Printf("upserted %d rows into `%s`", n, dataSource.ID)
+		}
+		_, err = dest.Exec("DROP TABLE " + staging)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var last interface{}
+	err = dest.QueryRow(fmt.Sprintf("SELECT MAX(%s) FROM %s", quoteIdentifier(incremental.CursorColumn), dataSource.ID)).Scan(&last)
+	if err != nil {
+		return nil, fmt.Errorf("read cursor of `%s`: %w", dataSource.ID, err)
+	}
+	if last == nil {
+		last = value
+	}
+	return &datasetCursor{
+		SourceHash: dataSourceHash(dataSource),
+		Value:      last,
+	}, nil
+}
+
+// copyFile copies a file, creating or truncating the destination.
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const incrementalDatasetConfig = `
+data_connections:
+  - id: src
+    type: sqlite
+    path: ./source.db
+datasets:
+  - id: events
+    data_source:
+      id: ev
+      data_connection: src
+      query: SELECT id, name, updated_at FROM events WHERE updated_at >= $1
+      incremental:
+        cursor_column: updated_at
+        primary_key: [id]
+        initial_value: 0
+`
+
+func TestIncrementalRefresh(t *testing.T) {
+	api := newTestAPI(t, incrementalDatasetConfig)
+	source, err := sql.Open("sqlite3", filepath.Join(api.dataDir, "source.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	exec := func(query string) {
+		t.Helper()
+		_, err := source.Exec(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	refresh := func() error {
+		t.Helper()
+		hash, err := api.LatestConfigHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return api.refreshDataset(hash, "events")
+	}
+	cursor := func() datasetCursor {
+		t.Helper()
+		cursors, err := api.readDatasetCursors("events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cursors["ev"]
+	}
+	expectRows := func(expected [][]interface{}) {
+		t.Helper()
+		records := testQueryDataset(t, api, "events", "SELECT id, name, updated_at FROM ev ORDER BY id")
+		if !reflect.DeepEqual(records, expected) {
+			t.Errorf("expected %v, got %v", expected, records)
+		}
+	}
+
+	exec(`CREATE TABLE events (id INTEGER, name TEXT, updated_at INTEGER);
+	INSERT INTO events VALUES (1, 'a', 1), (2, 'b', 2)`)
+	err = refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRows([][]interface{}{{int64(1), "a", int64(1)}, {int64(2), "b", int64(2)}})
+	first := cursor()
+	if first.Value != int64(2) || first.SourceHash == "" {
+		t.Errorf("expected the cursor to be stored at 2, got %+v", first)
+	}
+
+	// Only rows past the cursor are read, so the deleted row stays, and
+	// the updated row replaces the old one by its primary key.
+	exec(`UPDATE events SET name = 'a2', updated_at = 3 WHERE id = 1;
+	INSERT INTO events VALUES (3, 'c', 3);
+	DELETE FROM events WHERE id = 2`)
+	err = refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRows([][]interface{}{{int64(1), "a2", int64(3)}, {int64(2), "b", int64(2)}, {int64(3), "c", int64(3)}})
+	if c := cursor(); c.Value != int64(3) || c.SourceHash != first.SourceHash {
+		t.Errorf("expected the cursor to move to 3, got %+v", c)
+	}
+	staging := testQueryDataset(t, api, "events", "SELECT name FROM sqlite_master WHERE name = '_incremental_ev'")
+	if len(staging) > 0 {
+		t.Error("expected the staging table to be dropped")
+	}
+
+	// A failed build keeps the previous cursor.
+	exec(`ALTER TABLE events RENAME TO renamed_events;
+	INSERT INTO renamed_events VALUES (4, 'd', 4)`)
+	err = refresh()
+	if err == nil {
+		t.Fatal("expected the refresh to fail")
+	}
+	if c := cursor(); c.Value != int64(3) {
+		t.Errorf("expected the cursor to stay at 3, got %+v", c)
+	}
+	exec(`ALTER TABLE renamed_events RENAME TO events`)
+
+	// Changing the query resets the cursor, so all rows are read again.
+	loadTestConfig(t, api, incrementalDatasetConfig+"      columns:\n        name: text\n")
+	err = refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRows([][]interface{}{{int64(1), "a2", int64(3)}, {int64(3), "c", int64(3)}, {int64(4), "d", int64(4)}})
+	if c := cursor(); c.Value != int64(4) || c.SourceHash == first.SourceHash {
+		t.Errorf("expected the cursor to be reset for the new query, got %+v", c)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/crossjoin-io/crossjoin/config"
+)
+
+// datasetFilename returns the path of a version of a dataset, or of the
+// current version if version is empty. Versions are named after the
+// refresh that produced them.
+func (api *API) datasetFilename(id, version string) string {
+	if version == "" {
+		return filepath.Join(api.dataDir, id+".db")
+	}
+	return filepath.Join(api.dataDir, "versions", id, version+".db")
+}
+
+// storeDatasetVersion keeps a copy of a built dataset file as a version.
+// Dataset files are never modified after they're built, so a hard link
+// is used when possible.
+func (api *API) storeDatasetVersion(id, version, filename string) error {
+	versionFilename := api.datasetFilename(id, version)
+	err := os.MkdirAll(filepath.Dir(versionFilename), 0755)
+	if err != nil {
+		return err
+	}
+	if os.Link(filename, versionFilename) == nil {
+		return nil
+	}
+	return copyFile(filename, versionFilename)
+}
+
+// latestDatasetVersions returns the latest available version of each of
+// the datasets. Datasets without an available version are left out.
+func (api *API) latestDatasetVersions(ids []string) (map[string]string, error) {
+	versions := map[string]string{}
+	for _, id := range ids {
+		version := ""
+		err := api.db.QueryRow("SELECT id FROM dataset_refreshes WHERE dataset_id = $1 AND success = 1 ORDER BY started_at DESC, rowid DESC LIMIT 1",
+			id).Scan(&version)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("query latest version of %s: %w", id, err)
+		}
+		if _, err := os.Stat(api.datasetFilename(id, version)); err != nil {
+			continue
+		}
+		versions[id] = version
+	}
+	return versions, nil
+}
+
+// pruneDatasetVersions removes versions of a dataset that are outside of
+// its retention. The latest version and versions used by workflow runs
+// that are still running are always kept.
+func (api *API) pruneDatasetVersions(dataset config.Dataset, now time.Time) error {
+	maxVersions := 1
+	maxAge := time.Duration(0)
+	if dataset.Retention != nil {
+		maxVersions = dataset.Retention.Versions
+		if dataset.Retention.MaxAge != "" {
+			var err error
+			maxAge, err = config.ParseDuration(dataset.Retention.MaxAge)
+			if err != nil {
+				return err
+			}
+		}
+		if maxVersions == 0 && maxAge == 0 {
+			maxVersions = 1
+		}
+	}
+
+	pinned, err := api.pinnedDatasetVersions(dataset.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := api.db.Query("SELECT id, started_at FROM dataset_refreshes WHERE dataset_id = $1 AND success = 1 ORDER BY started_at DESC, rowid DESC",
+		dataset.ID)
+	if err != nil {
+		return fmt.Errorf("query versions: %w", err)
+	}
+	defer rows.Close()
+	for i := 0; rows.Next(); i++ {
+		version := ""
+		startedAt := time.Time{}
+		err = rows.Scan(&version, &startedAt)
+		if err != nil {
+			return fmt.Errorf("scan version: %w", err)
+		}
+		if i == 0 || pinned[version] {
+			continue
+		}
+		if (maxVersions == 0 || i < maxVersions) && (maxAge == 0 || startedAt.After(now.Add(-maxAge))) {
+			continue
+		}
+		err = os.Remove(api.datasetFilename(dataset.ID, version))
+		if err == nil {
+			log.Printf("removed version %s of dataset %s", version, dataset.ID)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// pinnedDatasetVersions returns the versions of a dataset used by workflow
+// runs that haven't completed yet.
+func (api *API) pinnedDatasetVersions(id string) (map[string]bool, error) {
+	rows, err := api.db.Query("SELECT dataset_versions FROM workflow_runs WHERE completed_at IS NULL")
+	if err != nil {
+		return nil, fmt.Errorf("query workflow runs: %w", err)
+	}
+	defer rows.Close()
+	pinned := map[string]bool{}
+	for rows.Next() {
+		var text []byte
+		err = rows.Scan(&text)
+		if err != nil {
+			return nil, fmt.Errorf("scan workflow run: %w", err)
+		}
+		versions := map[string]string{}
+		err = json.Unmarshal(text, &versions)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal dataset versions: %w", err)
+		}
+		if version, ok := versions[id]; ok {
+			pinned[version] = true
+		}
+	}
+	return pinned, rows.Err()
+}
@@ -2,21 +2,21 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
-	"encoding/csv"
-	"errors"
+	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/crossjoin-io/crossjoin/config"
-	_ "github.com/lib/pq"
+	"github.com/crossjoin-io/crossjoin/connector"
+	"github.com/google/uuid"
 	"gopkg.in/yaml.v2"
 )
 
@@ -66,7 +66,7 @@ func (api *API) ReadDatasets() ([]config.Dataset, error) {
 }
 
 func (api *API) PreviewDataset(id string) ([]interface{}, error) {
-	filename := filepath.Join(api.dataDir, id+".db")
+	filename := api.datasetFilename(id, "")
 	db, err := sql.Open("sqlite3", filename)
 	if err != nil {
 		return nil, err
@@ -115,10 +115,44 @@ func (api *API) refreshDataset(hash string, id string) error {
 	if err != nil {
 		return err
 	}
-	err = api.createDataset(hash, dataset)
+
+	refreshID, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec("INSERT INTO dataset_refreshes (id, dataset_id, config_hash, started_at) VALUES ($1, $2, $3, datetime('now'))",
+		refreshID.String(), id, hash)
+	if err != nil {
+		return fmt.Errorf("store refresh: %w", err)
+	}
+	rowCounts, err := api.createDataset(hash, dataset, refreshID.String())
+	if err != nil {
+		err = fmt.Errorf("create dataset: %w", err)
+		_, storeErr := api.db.Exec("UPDATE dataset_refreshes SET completed_at = datetime('now'), success = 0, error = $1 WHERE id = $2",
+			err.Error(), refreshID.String())
+		if storeErr != nil {
+			log.Println(fmt.Errorf("store refresh error: %w", storeErr))
+		}
+		return err
+	}
+	var fileSize int64
+	if info, err := os.Stat(api.datasetFilename(dataset.ID, "")); err == nil {
+		fileSize = info.Size()
+	}
+	marshaledRowCounts, err := json.Marshal(rowCounts)
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec("UPDATE dataset_refreshes SET completed_at = datetime('now'), success = 1, row_counts = $1, file_size = $2 WHERE id = $3",
+		marshaledRowCounts, fileSize, refreshID.String())
+	if err != nil {
+		return fmt.Errorf("store refresh: %w", err)
+	}
+	err = api.pruneDatasetVersions(dataset, time.Now())
 	if err != nil {
-		return fmt.Errorf("create dataset: %w", err)
+		log.Println(fmt.Errorf("prune versions of %s: %w", dataset.ID, err))
 	}
+
 	workflows, err := api.GetWorkflows(hash)
 	if err != nil {
 		return fmt.Errorf("get workflows: %w", err)
@@ -140,189 +174,305 @@ func (api *API) refreshDataset(hash string, id string) error {
 	return nil
 }
 
-func (api *API) createDataset(hash string, dataset config.Dataset) error {
-	filename := filepath.Join(api.dataDir, dataset.ID+".db")
+// createDataset materializes the dataset and returns the number of rows
+// in each of its data sources and the joined table. The dataset is built
+// in a temporary file which replaces the previous version only once it's
+// complete, so readers never see a partially written dataset. The new
+// file is also kept as the given version.
+func (api *API) createDataset(hash string, dataset config.Dataset, version string) (map[string]int64, error) {
+	filename := api.datasetFilename(dataset.ID, "")
+
+	// Remove temporary files left behind by refreshes that were interrupted.
+	staleFiles, err := filepath.Glob(filename + ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	for _, staleFile := range staleFiles {
+		log.Printf("removing stale `%s`", staleFile)
+		os.Remove(staleFile)
+	}
+
+	tmpFile, err := os.CreateTemp(api.dataDir, dataset.ID+".db.tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	tmpFile.Close()
+	tmpFilename := tmpFile.Name()
+	defer os.Remove(tmpFilename)
 
-	// Does the file exist? If so, remove it.
-	_, err := os.Stat(filename)
-	if err == nil {
-		log.Printf("`%s` already exists; removing", filename)
-		os.Remove(filename)
+	storedCursors, err := api.readDatasetCursors(dataset.ID)
+	if err != nil {
+		return nil, fmt.Errorf("read cursors: %w", err)
+	}
+	// Incremental data sources are upserted into a copy of the current
+	// version, since versions are never modified.
+	cursors := incrementalCursors(dataset, storedCursors)
+	if len(cursors) > 0 {
+		err = copyFile(filename, tmpFilename)
+		if os.IsNotExist(err) {
+			cursors = nil
+		} else if err != nil {
+			return nil, err
+		}
 	}
 
-	db, err := sql.Open("sqlite3", filename)
+	rowCounts, cursors, err := api.buildDataset(hash, dataset, tmpFilename, cursors)
+	if err != nil {
+		return nil, err
+	}
+	err = validateDatasetFile(tmpFilename, dataset.ID)
+	if err != nil {
+		return nil, fmt.Errorf("validate dataset: %w", err)
+	}
+	err = api.storeDatasetVersion(dataset.ID, version, tmpFilename)
+	if err != nil {
+		return nil, fmt.Errorf("store version: %w", err)
+	}
+	err = os.Rename(tmpFilename, filename)
+	if err != nil {
+		return nil, err
+	}
+	err = api.storeDatasetCursors(dataset.ID, cursors)
+	if err != nil {
+		return nil, fmt.Errorf("store cursors: %w", err)
+	}
+	return rowCounts, nil
+}
+
+// validateDatasetFile checks that a built dataset file is intact and
+// contains the dataset table.
+func validateDatasetFile(filename, id string) error {
+	db, err := sql.Open("sqlite3", "file:"+filename+"?mode=ro")
 	if err != nil {
 		return err
 	}
 	defer db.Close()
-	_, err = db.Exec("PRAGMA synchronous = OFF")
+	result := ""
+	err = db.QueryRow("PRAGMA integrity_check").Scan(&result)
 	if err != nil {
 		return err
 	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check: %s", result)
+	}
+	err = db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = $1", id).Scan(&result)
+	if err != nil {
+		return fmt.Errorf("find table `%s`: %w", id, err)
+	}
+	return nil
+}
+
+// buildDataset fetches the data sources of a dataset and joins them. Data
+// sources with a cursor are upserted into the tables already in the file.
+// It returns the row counts and the new cursors of incremental data
+// sources.
+func (api *API) buildDataset(hash string, dataset config.Dataset, filename string, cursors map[string]datasetCursor) (map[string]int64, map[string]datasetCursor, error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+	_, err = db.Exec("PRAGMA synchronous = OFF")
+	if err != nil {
+		return nil, nil, err
+	}
 	_, err = db.Exec("PRAGMA journal_mode = MEMORY")
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	_, err = db.Exec("PRAGMA cache_size = -2000000")
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	newCursors := map[string]datasetCursor{}
+	fetch := func(dataSource *config.DataSource) error {
+		var cursor *datasetCursor
+		if c, ok := cursors[dataSource.ID]; ok {
+			cursor = &c
+		}
+		newCursor, err := api.fetchSingle(hash, db, dataSource, cursor)
+		if err != nil {
+			return err
+		}
+		if newCursor != nil {
+			newCursors[dataSource.ID] = *newCursor
+		}
+		return nil
 	}
 
 	log.Printf("querying `%s`", dataset.DataSource.ID)
-	err = api.fetchSingle(hash, db, dataset.DataSource)
+	err = fetch(dataset.DataSource)
 	if err != nil {
-		return fmt.Errorf("fetch single: %w", err)
+		return nil, nil, fmt.Errorf("fetch single: %w", err)
 	}
 
 	for _, join := range dataset.Joins {
 		log.Printf("querying `%s`", join.DataSource.ID)
-		err = api.fetchSingle(hash, db, join.DataSource)
+		err = fetch(join.DataSource)
 		if err != nil {
-			return fmt.Errorf("fetch single as part of join: %w", err)
-		}
-	}
-
-	joinClauses := ""
-	for _, join := range dataset.Joins {
-		joinColumns := []string{}
-		for _, cols := range join.Columns {
-			joinColumns = append(joinColumns, fmt.Sprintf(`%s."%s" = %s."%s"`, dataset.DataSource.ID, cols.LeftColumn, join.DataSource.ID, cols.RightColumn))
+			return nil, nil, fmt.Errorf("fetch single as part of join: %w", err)
 		}
-		joinClauses += fmt.Sprintf(" %s %s ON %s", join.Type, join.DataSource.ID, strings.Join(joinColumns, " AND "))
 	}
 
 	log.Println("joining data")
-	joinQuery := fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s %s", dataset.ID, dataset.DataSource.ID, joinClauses)
-	_, err = db.Exec(joinQuery)
-	return err
-}
-
-func (api *API) fetchSingle(hash string, dest *sql.DB, dataSource *config.DataSource) error {
-	dataConnection, err := api.ReadDataConnection(hash, dataSource.DataConnection)
+	_, err = db.Exec("DROP TABLE IF EXISTS " + dataset.ID)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	_, err = db.Exec(fmt.Sprintf("CREATE TABLE %s AS %s", dataset.ID, dataset.Query()))
+	if err != nil {
+		return nil, nil, err
 	}
-	switch dataConnection.Type {
-	case "csv":
-		f, err := api.readFile(dataConnection.Path)
-		if err != nil {
-			return err
-		}
-		r := csv.NewReader(f)
-		firstLine, err := r.Read()
-		if err != nil {
-			return err
-		}
-		columns := firstLine
-		for i := range columns {
-			columns[i] = strconv.Quote(columns[i])
-		}
-
-		_, err = dest.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", dataSource.ID, strings.Join(columns, ",")))
-		if err != nil {
-			return err
-		}
 
-		params := []string{}
-		for i := range columns {
-			params = append(params, fmt.Sprintf("$%d", i+1))
-		}
-		stmt, err := dest.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (%s)", dataSource.ID, strings.Join(params, ",")))
+	rowCounts := map[string]int64{}
+	tables := []string{dataset.DataSource.ID, dataset.ID}
+	for _, join := range dataset.Joins {
+		tables = append(tables, join.DataSource.ID)
+	}
+	for _, table := range tables {
+		var count int64
+		err = db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)
 		if err != nil {
-			return err
+			return nil, nil, fmt.Errorf("count rows: %w", err)
 		}
-		defer stmt.Close()
+		rowCounts[table] = count
+	}
+	return rowCounts, newCursors, nil
+}
 
-		for {
-			record, err := r.Read()
-			if err != nil {
-				if err == io.EOF {
-					return nil
-				}
-				return err
-			}
-			if len(record) != len(columns) {
-				return errors.New("inconsistent number of fields")
-			}
-			values := make([]interface{}, len(record))
-			for i := range record {
-				values[i] = record[i]
-			}
-			_, err = stmt.Exec(values...)
-			if err != nil {
-				return err
-			}
-		}
-	case "postgres":
-		db, err := sql.Open(dataConnection.Type, dataConnection.ConnectionString)
+// fetchSingle reads a data source into a table. Incremental data sources
+// with a cursor are upserted into their table, and their new cursor is
+// returned.
+func (api *API) fetchSingle(hash string, dest *sql.DB, dataSource *config.DataSource, cursor *datasetCursor) (*datasetCursor, error) {
+	if cursor == nil {
+		_, err := dest.Exec("DROP TABLE IF EXISTS " + dataSource.ID)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		defer db.Close()
-
-		rows, err := db.Query(dataSource.Query)
+	}
+	if dataSource.Dataset != "" {
+		rows, err := api.readDataset(context.Background(), dataSource)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer rows.Close()
+		return nil, copyRows(dest, dataSource.ID, dataSource, rows)
+	}
 
-		columns, err := rows.Columns()
-		if err != nil {
-			return err
-		}
+	dataConnection, err := api.ReadDataConnection(hash, dataSource.DataConnection)
+	if err != nil {
+		return nil, err
+	}
+	if dataSource.Incremental != nil {
+		return fetchIncremental(dest, dataConnection, dataSource, cursor)
+	}
+	rows, err := connector.Open(context.Background(), dataConnection, dataSource)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return nil, copyRows(dest, dataSource.ID, dataSource, rows)
+}
 
-		for i := range columns {
-			columns[i] = `"` + columns[i] + `"`
-		}
+// readDataset runs the query of a data source on the latest version of
+// the dataset it reads from.
+func (api *API) readDataset(ctx context.Context, dataSource *config.DataSource) (connector.Rows, error) {
+	filename := api.datasetFilename(dataSource.Dataset, "")
+	if _, err := os.Stat(filename); err != nil {
+		return nil, fmt.Errorf("dataset `%s` hasn't been refreshed yet", dataSource.Dataset)
+	}
+	db, err := sql.Open("sqlite3", "file:"+filename+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	query := dataSource.Query
+	if query == "" {
+		query = "SELECT * FROM " + dataSource.Dataset
+	}
+	return queryRows(ctx, db, query)
+}
 
-		_, err = dest.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", dataSource.ID, strings.Join(columns, ",")))
-		if err != nil {
-			return err
+// createTable creates a table with the columns in dest and prepares
+// a statement to insert rows into it. Columns are untyped unless
+// types are given.
+func createTable(dest *sql.DB, table string, columns []string, types []string) (*sql.Stmt, error) {
+	quoted := make([]string, len(columns))
+	params := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+		if types != nil && types[i] != "" {
+			quoted[i] += " " + types[i]
 		}
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
 
-		params := []string{}
-		for i := range columns {
-			params = append(params, fmt.Sprintf("$%d", i+1))
-		}
-		stmt, err := dest.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (%s)", dataSource.ID, strings.Join(params, ",")))
-		if err != nil {
-			return err
-		}
-		defer stmt.Close()
+	_, err := dest.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", table, strings.Join(quoted, ",")))
+	if err != nil {
+		return nil, err
+	}
+	return dest.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (%s)", table, strings.Join(params, ",")))
+}
 
-		for rows.Next() {
-			cols := make([]interface{}, len(columns))
-			colPointers := make([]interface{}, len(cols))
-			for i := range cols {
-				colPointers[i] = &cols[i]
-			}
+func quoteIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
 
-			if err := rows.Scan(colPointers...); err != nil {
-				return err
-			}
+// copyRows creates a table in dest and copies the rows of a data source
+// into it. Text in columns whose types are overridden by the data source
+// is converted to the type.
+func copyRows(dest *sql.DB, table string, dataSource *config.DataSource, rows connector.Rows) error {
+	columns := rows.Columns()
+	names := make([]string, len(columns))
+	types := make([]string, len(columns))
+	indexes := map[string]int{}
+	for i, column := range columns {
+		names[i] = column.Name
+		types[i] = column.Type
+		indexes[column.Name] = i
+	}
+	overridden := map[int]string{}
+	for column, typ := range dataSource.Columns {
+		i, ok := indexes[column]
+		if !ok {
+			return fmt.Errorf("unknown column `%s` in the column types of `%s`", column, dataSource.ID)
+		}
+		types[i] = config.ColumnTypes[typ]
+		overridden[i] = typ
+	}
 
-			values := []interface{}{}
-			for i := range columns {
-				val := colPointers[i].(*interface{})
-				values = append(values, *val)
-			}
+	stmt, err := createTable(dest, table, names, types)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
 
-			_, err = stmt.Exec(values...)
-			if err != nil {
-				return err
+	for rows.Next() {
+		values := rows.Values()
+		for i, typ := range overridden {
+			s, ok := values[i].(string)
+			if !ok {
+				continue
+			}
+			values[i], ok = parseValue(s, types[i])
+			if !ok {
+				return fmt.Errorf("column `%s`: invalid %s `%s`", names[i], typ, s)
 			}
 		}
+		_, err = stmt.Exec(values...)
+		if err != nil {
+			return err
+		}
 	}
-
-	return nil
+	return rows.Err()
 }
 
-func (api *API) readFile(path string) (io.Reader, error) {
+func readFile(path string) (*bytes.Reader, error) {
 	log.Printf("reading file `%s`", path)
 	urlPath, _ := url.Parse(path)
-	if urlPath != nil {
+	if urlPath != nil && urlPath.Scheme != "" {
 		if strings.Contains(path, "api.github.com") {
-			contents, err := api.fetchGitHubFile(path)
+			contents, err := fetchGitHubFile(path)
 			if err != nil {
 				return nil, fmt.Errorf("read file: %w", err)
 			}
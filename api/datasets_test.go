@@ -0,0 +1,113 @@
+package api
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFailedRefreshKeepsDataset(t *testing.T) {
+	api := newTestAPI(t, `
+data_connections:
+  - id: orders
+    type: csv
+    path: ./orders.csv
+datasets:
+  - id: totals
+    data_source:
+      id: raw_orders
+      data_connection: orders
+    transform: SELECT region, SUM(amount) AS total FROM raw_orders GROUP BY region
+`)
+	ordersFile := filepath.Join(api.dataDir, "orders.csv")
+	hash, err := api.LatestConfigHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh := func(csv string) error {
+		t.Helper()
+		err := os.WriteFile(ordersFile, []byte(csv), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return api.refreshDataset(hash, "totals")
+	}
+	expected := [][]interface{}{{"East", int64(5)}, {"West", int64(10)}}
+	expectDataset := func() {
+		t.Helper()
+		records := testQueryDataset(t, api, "totals", "SELECT region, total FROM totals ORDER BY region")
+		if !reflect.DeepEqual(records, expected) {
+			t.Errorf("expected %v, got %v", expected, records)
+		}
+		tmpFiles, err := filepath.Glob(filepath.Join(api.dataDir, "*.tmp-*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tmpFiles) > 0 {
+			t.Errorf("expected the temporary files to be removed, got %v", tmpFiles)
+		}
+	}
+
+	err = refresh("region,amount\nWest,10\nEast,5\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectDataset()
+
+	// The data source is read, but the transform fails without the
+	// amount column.
+	err = refresh("region,quantity\nWest,1\n")
+	if err == nil {
+		t.Fatal("expected the refresh to fail")
+	}
+	expectDataset()
+
+	// Temporary files left behind by an interrupted refresh are removed
+	// by the next one.
+	err = os.WriteFile(filepath.Join(api.dataDir, "totals.db.tmp-1"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = refresh("region\n")
+	if err == nil {
+		t.Fatal("expected the refresh to fail")
+	}
+	expectDataset()
+}
+
+func TestValidateDatasetFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "totals.db")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE raw_orders (region TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = validateDatasetFile(filename, "totals")
+	if err == nil {
+		t.Error("expected an error for a file without the dataset table")
+	}
+	_, err = db.Exec("CREATE TABLE totals (region TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = validateDatasetFile(filename, "totals")
+	if err != nil {
+		t.Error(err)
+	}
+
+	db.Close()
+	err = os.WriteFile(filename, []byte("not a database"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = validateDatasetFile(filename, "totals")
+	if err == nil {
+		t.Error("expected an error for a corrupt file")
+	}
+}
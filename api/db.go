@@ -2,7 +2,9 @@ package api
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"time"
 )
 
 // setupDatabase sets up the database and schema migrations.
@@ -59,6 +61,77 @@ func setupDatabase(db *sql.DB) error {
 			PRIMARY KEY (config_hash, id)
 		)
 		`,
+		/* 002 */ `
+		CREATE UNIQUE INDEX IF NOT EXISTS tasks_workflow_run_id_workflow_task_id ON tasks (workflow_run_id, workflow_task_id)
+		`,
+		/* 003 */ `
+		ALTER TABLE tasks ADD COLUMN type TEXT NOT NULL DEFAULT 'container';
+		ALTER TABLE tasks ADD COLUMN run_at TIMESTAMP;
+		`,
+		/* 004 */ `
+		CREATE TABLE IF NOT EXISTS task_attempts (
+			id TEXT NOT NULL PRIMARY KEY,
+			task_id TEXT NOT NULL,
+			runner TEXT NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			completed_at TIMESTAMP,
+			success BOOL,
+			exit_code INT,
+			stdout TEXT,
+			stderr TEXT,
+			output JSON
+		);
+		CREATE INDEX IF NOT EXISTS task_attempts_task_id ON task_attempts (task_id);
+		`,
+		/* 005 */ `
+		CREATE TABLE IF NOT EXISTS workflow_schedules (
+			workflow_id TEXT NOT NULL,
+			schedule TEXT NOT NULL,
+			last_fired_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (workflow_id, schedule)
+		)
+		`,
+		/* 006 */ `
+		CREATE TABLE IF NOT EXISTS dataset_schedules (
+			dataset_id TEXT NOT NULL PRIMARY KEY,
+			last_refreshed_at TIMESTAMP NOT NULL
+		)
+		`,
+		/* 007 */ `
+		CREATE TABLE IF NOT EXISTS dataset_refreshes (
+			id TEXT NOT NULL PRIMARY KEY,
+			dataset_id TEXT NOT NULL,
+			config_hash TEXT NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			completed_at TIMESTAMP,
+			success BOOL,
+			error TEXT,
+			row_counts JSON,
+			file_size INT
+		);
+		CREATE INDEX IF NOT EXISTS dataset_refreshes_dataset_id ON dataset_refreshes (dataset_id, started_at);
+		`,
+		/* 008 */ `
+		ALTER TABLE workflow_runs ADD COLUMN input JSON NOT NULL DEFAULT '{}';
+		ALTER TABLE workflow_runs ADD COLUMN dataset_versions JSON NOT NULL DEFAULT '{}';
+		`,
+		/* 009 */ `
+		ALTER TABLE data_connections ADD COLUMN text TEXT;
+		`,
+		/* 010 */ `
+		CREATE TABLE IF NOT EXISTS dataset_cursors (
+			dataset_id TEXT NOT NULL,
+			data_source_id TEXT NOT NULL,
+			source_hash TEXT NOT NULL,
+			value,
+			updated_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (dataset_id, data_source_id)
+		)
+		`,
+		/* 011 */ `
+		ALTER TABLE tasks ADD COLUMN skipped BOOL NOT NULL DEFAULT 0;
+		ALTER TABLE tasks ADD COLUMN chosen_branch TEXT;
+		`,
 	}
 
 	tx, err := db.Begin()
@@ -106,3 +179,9 @@ func setupDatabase(db *sql.DB) error {
 
 	return tx.Commit()
 }
+
+// sqliteModifier returns a SQLite date and time modifier that adds
+// the duration, e.g. for use with datetime('now', $1).
+func sqliteModifier(d time.Duration) string {
+	return fmt.Sprintf("+%d seconds", int64(d.Seconds()))
+}
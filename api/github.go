@@ -17,7 +17,7 @@ type GitHubContentResponse struct {
 	Encoding string `json:"encoding"`
 }
 
-func (api *API) fetchGitHubFile(path string) ([]byte, error) {
+func fetchGitHubFile(path string) ([]byte, error) {
 	req, err := http.NewRequest("GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
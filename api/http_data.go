@@ -0,0 +1,395 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crossjoin-io/crossjoin/config"
+	"github.com/crossjoin-io/crossjoin/connector"
+)
+
+const httpDataRequestTimeout = time.Minute
+
+// httpConnector reads data connections of HTTP APIs.
+type httpConnector struct {
+	config.DataConnectionValidator
+}
+
+func (httpConnector) Open(ctx context.Context, dataConnection *config.DataConnection, dataSource *config.DataSource) (connector.Rows, error) {
+	columns, records, err := fetchHTTP(ctx, dataConnection)
+	if err != nil {
+		return nil, err
+	}
+	return connector.NewRows(connector.UntypedColumns(columns), records), nil
+}
+
+// fetchHTTP fetches the records of an http connection, following the
+// pagination until there are no pages left.
+func fetchHTTP(ctx context.Context, dataConnection *config.DataConnection) ([]string, [][]interface{}, error) {
+	pagination := dataConnection.Pagination
+	if pagination == nil {
+		pagination = &config.Pagination{}
+	}
+	page := 1
+	if pagination.StartPage != nil {
+		page = *pagination.StartPage
+	}
+
+	var (
+		jsonValues []interface{}
+		csvColumns []string
+		csvRecords [][]interface{}
+	)
+	nextURL := os.ExpandEnv(dataConnection.URL)
+	seenCursors := map[string]bool{}
+	seenURLs := map[string]bool{}
+	var lastBody []byte
+	for requests := 0; nextURL != ""; requests++ {
+		if pagination.MaxPages > 0 && requests >= pagination.MaxPages {
+			break
+		}
+
+		requestURL := nextURL
+		if pagination.Type == "page" {
+			u, err := url.Parse(nextURL)
+			if err != nil {
+				return nil, nil, err
+			}
+			q := u.Query()
+			q.Set(pagination.PageParam, strconv.Itoa(page))
+			if pagination.PageSizeParam != "" && pagination.PageSize > 0 {
+				q.Set(pagination.PageSizeParam, strconv.Itoa(pagination.PageSize))
+			}
+			u.RawQuery = q.Encode()
+			requestURL = u.String()
+			page++
+		}
+
+		body, header, err := httpDataRequest(ctx, dataConnection, requestURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		// An API that ignores the page param returns the same page again.
+		if pagination.Type == "page" && lastBody != nil && bytes.Equal(body, lastBody) {
+			log.Printf("`%s` returned the previous page again; stopping", requestURL)
+			break
+		}
+		lastBody = body
+		// An empty response is an empty last page.
+		if len(bytes.TrimSpace(body)) == 0 {
+			break
+		}
+
+		count := 0
+		var response interface{}
+		switch dataConnection.Format {
+		case "csv":
+			columns, records, err := readCSVPage(body, csvColumns)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", requestURL, err)
+			}
+			csvColumns = columns
+			csvRecords = append(csvRecords, records...)
+			count = len(records)
+		case "jsonl":
+			values, err := decodeJSONValues(bytes.NewReader(body), true)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", requestURL, err)
+			}
+			jsonValues = append(jsonValues, values...)
+			count = len(values)
+		default:
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			err = dec.Decode(&response)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: decode response: %w", requestURL, err)
+			}
+			selected, err := selectJSON(response, dataConnection.Records)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", requestURL, err)
+			}
+			values, ok := selected.([]interface{})
+			if !ok {
+				return nil, nil, fmt.Errorf("%s: expected an array of records", requestURL)
+			}
+			jsonValues = append(jsonValues, values...)
+			count = len(values)
+		}
+
+		// An empty page ends the pagination, even if it links to another.
+		if count == 0 {
+			break
+		}
+		switch pagination.Type {
+		case "cursor":
+			cursor, err := selectJSON(response, pagination.CursorPath)
+			if err != nil || cursor == nil || fmt.Sprint(cursor) == "" || seenCursors[fmt.Sprint(cursor)] {
+				nextURL = ""
+				break
+			}
+			seenCursors[fmt.Sprint(cursor)] = true
+			u, err := url.Parse(os.ExpandEnv(dataConnection.URL))
+			if err != nil {
+				return nil, nil, err
+			}
+			q := u.Query()
+			q.Set(pagination.CursorParam, fmt.Sprint(cursor))
+			u.RawQuery = q.Encode()
+			nextURL = u.String()
+		case "page":
+			if pagination.PageSize > 0 && count < pagination.PageSize {
+				nextURL = ""
+			}
+		case "link_header":
+			seenURLs[requestURL] = true
+			nextURL, err = nextLink(requestURL, header.Values("link"))
+			if err != nil {
+				return nil, nil, err
+			}
+			if seenURLs[nextURL] {
+				log.Printf("`%s` links to a page that was already fetched; stopping", requestURL)
+				nextURL = ""
+			}
+		default:
+			nextURL = ""
+		}
+	}
+
+	if dataConnection.Format == "csv" {
+		if csvColumns == nil {
+			return nil, nil, fmt.Errorf("no columns found")
+		}
+		return csvColumns, csvRecords, nil
+	}
+	return jsonRecords(jsonValues, dataConnection.FlattenDepth)
+}
+
+func httpDataRequest(ctx context.Context, dataConnection *config.DataConnection, requestURL string) ([]byte, http.Header, error) {
+	log.Printf("fetching `%s`", requestURL)
+	ctx, cancel := context.WithTimeout(ctx, httpDataRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create request: %w", err)
+	}
+	for k, v := range dataConnection.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+	if auth := dataConnection.Auth; auth != nil {
+		switch auth.Type {
+		case "basic":
+			req.SetBasicAuth(os.ExpandEnv(auth.Username), os.ExpandEnv(auth.Password))
+		case "bearer":
+			req.Header.Set("authorization", "Bearer "+os.ExpandEnv(auth.Token))
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if len(body) > 512 {
+			body = body[:512]
+		}
+		return nil, nil, fmt.Errorf("%s: unexpected status %d: %s", requestURL, resp.StatusCode, body)
+	}
+	return body, resp.Header, nil
+}
+
+// readCSVPage reads a page of CSV records. Pages after the first need to
+// have the same columns.
+func readCSVPage(body []byte, columns []string) ([]string, [][]interface{}, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	header, err := r.Read()
+	if err == io.EOF {
+		return columns, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if columns == nil {
+		columns = header
+	}
+	order, err := csvColumnOrder(columns, header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("header doesn't match the first page: %w", err)
+	}
+
+	records := [][]interface{}{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return columns, records, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		values := make([]interface{}, len(columns))
+		for i, field := range record {
+			values[order[i]] = field
+		}
+		records = append(records, values)
+	}
+}
+
+// selectJSON selects a value with a JSONPath-style selector.
+func selectJSON(value interface{}, path string) (interface{}, error) {
+	segments, err := config.ParseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range segments {
+		switch s := segment.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("can't select `%s` from a non-object", s)
+			}
+			value = object[s]
+		case int:
+			array, ok := value.([]interface{})
+			if !ok || s >= len(array) {
+				return nil, fmt.Errorf("can't select index %d", s)
+			}
+			value = array[s]
+		}
+	}
+	return value, nil
+}
+
+// nextLink returns the URL of the next page from Link headers, resolved
+// relative to the current URL.
+func nextLink(currentURL string, headers []string) (string, error) {
+	for _, header := range headers {
+		for _, link := range parseLinkHeader(header) {
+			for _, rel := range strings.Fields(link.Params["rel"]) {
+				if !strings.EqualFold(rel, "next") {
+					continue
+				}
+				base, err := url.Parse(currentURL)
+				if err != nil {
+					return "", err
+				}
+				next, err := base.Parse(link.Target)
+				if err != nil {
+					return "", err
+				}
+				return next.String(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// linkValue is a link of a Link header.
+type linkValue struct {
+	Target string
+	// Params are keyed by their lowercase names. Only the first of
+	// repeated params is kept.
+	Params map[string]string
+}
+
+// parseLinkHeader parses a Link header as described in RFC 8288. Links
+// are separated by commas, which can also appear within targets and
+// quoted params. Malformed links are skipped.
+func parseLinkHeader(header string) []linkValue {
+	links := []linkValue{}
+	s := header
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return links
+		}
+		end := strings.IndexByte(s, '>')
+		if s[0] != '<' || end < 0 {
+			s = skipLinkValue(s)
+			continue
+		}
+		link := linkValue{Target: s[1:end], Params: map[string]string{}}
+		s = strings.TrimLeft(s[end+1:], " \t")
+		for strings.HasPrefix(s, ";") {
+			s = strings.TrimLeft(s[1:], " \t")
+			i := strings.IndexAny(s, "=;, \t")
+			if i < 0 {
+				i = len(s)
+			}
+			name := strings.ToLower(s[:i])
+			s = strings.TrimLeft(s[i:], " \t")
+			value := ""
+			if strings.HasPrefix(s, "=") {
+				value, s = parseLinkParamValue(strings.TrimLeft(s[1:], " \t"))
+				s = strings.TrimLeft(s, " \t")
+			}
+			if _, ok := link.Params[name]; name != "" && !ok {
+				link.Params[name] = value
+			}
+		}
+		if s != "" && s[0] != ',' {
+			s = skipLinkValue(s)
+			continue
+		}
+		links = append(links, link)
+	}
+}
+
+// parseLinkParamValue parses a token or quoted string at the start of s
+// and returns it and the rest of s.
+func parseLinkParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		i := strings.IndexAny(s, ";, \t")
+		if i < 0 {
+			return s, ""
+		}
+		return s[:i], s[i:]
+	}
+	value := strings.Builder{}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				value.WriteByte(s[i])
+			}
+		case '"':
+			return value.String(), s[i+1:]
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return value.String(), ""
+}
+
+// skipLinkValue skips the rest of a malformed link, up to the next comma
+// outside of quotes.
+func skipLinkValue(s string) string {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == ',':
+			return s[i:]
+		}
+	}
+	return ""
+}
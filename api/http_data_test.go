@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/crossjoin-io/crossjoin/config"
+)
+
+func TestNextLink(t *testing.T) {
+	for _, tc := range []struct {
+		header   string
+		expected string
+	}{
+		{`<https://api.example.com/items?page=2>; rel="next"`, "https://api.example.com/items?page=2"},
+		{`</items?page=2>; rel=next`, "https://api.example.com/items?page=2"},
+		{`<https://api.example.com/items?ids=1,2&page=2>; rel="next"`, "https://api.example.com/items?ids=1,2&page=2"},
+		{`<https://api.example.com/items?page=1>; rel="prev"; title="a, b; c", <https://api.example.com/items?page=3>; REL="last next"`,
+			"https://api.example.com/items?page=3"},
+		{`<https://api.example.com/items?page=9>; title="\"quoted\", next"; rel=last, </items?page=2>;rel=next`,
+			"https://api.example.com/items?page=2"},
+		{`malformed; rel="next", </items?page=2>; rel="next"`, "https://api.example.com/items?page=2"},
+		{`</items?page=3>; rel="next"; rel="last", </items?page=2>; rel="next"`, "https://api.example.com/items?page=3"},
+		{`</items?page=1>; rel="prev"`, ""},
+		{``, ""},
+	} {
+		next, err := nextLink("https://api.example.com/items?page=1", []string{tc.header})
+		if err != nil {
+			t.Errorf("%s: %s", tc.header, err)
+			continue
+		}
+		if next != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.header, tc.expected, next)
+		}
+	}
+}
+
+func TestFetchHTTPEmptyLastPage(t *testing.T) {
+	for _, tc := range []struct {
+		records     string
+		first, last string
+	}{
+		{"", `[{"id": 1}, {"id": 2}]`, ""},
+		{"", `[{"id": 1}, {"id": 2}]`, "[]"},
+		{"items", `{"items": [{"id": 1}, {"id": 2}]}`, `{"items": []}`},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Query().Get("page") {
+			case "":
+				w.Header().Set("link", `</items?page=2>; rel="next"`)
+				fmt.Fprint(w, tc.first)
+			case "2":
+				// The empty page still links to the next one.
+				w.Header().Set("link", `</items?page=3>; rel="next"`)
+				fmt.Fprint(w, tc.last)
+			default:
+				http.Error(w, "no such page", http.StatusNotFound)
+			}
+		}))
+		columns, records, err := fetchHTTP(context.Background(), &config.DataConnection{
+			ID:         "items",
+			Type:       "http",
+			URL:        server.URL + "/items",
+			Records:    tc.records,
+			Pagination: &config.Pagination{Type: "link_header"},
+		})
+		server.Close()
+		if err != nil {
+			t.Errorf("%q: %s", tc.last, err)
+			continue
+		}
+		if !reflect.DeepEqual(columns, []string{"id"}) || len(records) != 2 {
+			t.Errorf("%q: expected 2 records with an id, got %v %v", tc.last, columns, records)
+		}
+	}
+}
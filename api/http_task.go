@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/crossjoin-io/crossjoin/config"
+)
+
+// runHTTPTask sends the request described by an "http" task. The URL and
+// body are templates executed with the task input. The response status
+// and body become the task output.
+func runHTTPTask(t serverTask, result *TaskResult) error {
+	url, err := executeTemplate(t.Def.URL, t.Input)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	body, err := executeTemplate(t.Def.Body, t.Input)
+	if err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+	method := strings.ToUpper(t.Def.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	// Stay within the task timeout so a hanging request fails the
+	// attempt instead of being picked up again while still running.
+	ctx, cancel := context.WithTimeout(context.Background(), t.Def.TimeoutDuration())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	for k, v := range t.Def.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+	if body != "" && req.Header.Get("content-type") == "" {
+		req.Header.Set("content-type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	var decodedBody interface{} = string(respBody)
+	if strings.Contains(resp.Header.Get("content-type"), "json") {
+		err = json.Unmarshal(respBody, &decodedBody)
+		if err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+
+	result.OK = expectedStatus(t.Def, resp.StatusCode)
+	result.Output = map[string]interface{}{
+		"status": resp.StatusCode,
+		"body":   decodedBody,
+	}
+	if len(respBody) > 512 {
+		respBody = respBody[:512]
+	}
+	if result.OK {
+		result.Stdout = string(respBody)
+	} else {
+		result.Stderr = fmt.Sprintf("unexpected status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+func expectedStatus(task *config.WorkflowTask, status int) bool {
+	if len(task.ExpectedStatus) == 0 {
+		return status/100 == 2
+	}
+	for _, expected := range task.ExpectedStatus {
+		if status == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func executeTemplate(text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New("").Funcs(config.TemplateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// decodeJSONValues decodes a JSON array, or newline-delimited values if
+// lines is set. Numbers are decoded as json.Number.
+func decodeJSONValues(r io.Reader, lines bool) ([]interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	if !lines {
+		var value interface{}
+		err := dec.Decode(&value)
+		if err != nil {
+			return nil, err
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an array of objects")
+		}
+		return values, nil
+	}
+
+	values := []interface{}{}
+	for {
+		var value interface{}
+		err := dec.Decode(&value)
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(values)+1, err)
+		}
+		values = append(values, value)
+	}
+}
+
+// jsonRecords turns JSON objects into records. Nested objects are
+// flattened into dotted column names up to maxDepth levels (unlimited if
+// nil); deeper objects and arrays are stored as JSON text. The columns
+// are the union of the keys of all records, in order of first appearance.
+func jsonRecords(values []interface{}, maxDepth *int) ([]string, [][]interface{}, error) {
+	columns := []string{}
+	columnIndexes := map[string]int{}
+	flattened := []map[string]interface{}{}
+	for i, value := range values {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("record %d is not an object", i+1)
+		}
+
+		record := map[string]interface{}{}
+		err := flattenJSON(record, "", object, 0, maxDepth)
+		if err != nil {
+			return nil, nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		for _, key := range sortedKeys(record) {
+			if _, ok := columnIndexes[key]; !ok {
+				columnIndexes[key] = len(columns)
+				columns = append(columns, key)
+			}
+		}
+		flattened = append(flattened, record)
+	}
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("no columns found")
+	}
+
+	records := make([][]interface{}, len(flattened))
+	for i, record := range flattened {
+		values := make([]interface{}, len(columns))
+		for key, value := range record {
+			values[columnIndexes[key]] = value
+		}
+		records[i] = values
+	}
+	return columns, records, nil
+}
+
+func flattenJSON(dest map[string]interface{}, prefix string, object map[string]interface{}, depth int, maxDepth *int) error {
+	for key, value := range object {
+		column := prefix + key
+		if nested, ok := value.(map[string]interface{}); ok && (maxDepth == nil || depth < *maxDepth) {
+			err := flattenJSON(dest, column+".", nested, depth+1, maxDepth)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		converted, err := jsonValue(value)
+		if err != nil {
+			return err
+		}
+		dest[column] = converted
+	}
+	return nil
+}
+
+// jsonValue converts a decoded JSON value into a value that can be
+// stored in SQLite.
+func jsonValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	default:
+		return v, nil
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,492 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/crossjoin-io/crossjoin/connector"
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+)
+
+type parquetColumn struct {
+	Name string
+	Leaf parquet.LeafColumn
+}
+
+// parquetRows reads the rows of Parquet files. All of the files need to
+// have the same columns.
+type parquetRows struct {
+	files   []dataFile
+	columns []parquetColumn
+	byIndex map[int]int
+
+	// The file being read, and the rows read from it but not returned
+	// yet.
+	index  int
+	file   dataFileReader
+	reader *parquet.Reader
+	buf    []parquet.Row
+	next   int
+	n      int
+
+	values []interface{}
+	err    error
+}
+
+func openParquet(files []dataFile) (*parquetRows, error) {
+	rows := &parquetRows{
+		files: files,
+		buf:   make([]parquet.Row, 128),
+	}
+	err := rows.openFile(0)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (rows *parquetRows) openFile(index int) error {
+	dataFile := rows.files[index]
+	f, size, err := dataFile.Open()
+	if err != nil {
+		return err
+	}
+	file, err := parquet.OpenFile(f, size)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("open %s: %w", dataFile.Name, err)
+	}
+
+	fileColumns := parquetColumns(file.Schema())
+	if rows.columns == nil {
+		rows.columns = fileColumns
+		rows.byIndex = map[int]int{}
+		for i, column := range rows.columns {
+			rows.byIndex[column.Leaf.ColumnIndex] = i
+		}
+	} else if !sameParquetColumns(rows.columns, fileColumns) {
+		f.Close()
+		return fmt.Errorf("columns of %s don't match %s", dataFile.Name, rows.files[0].Name)
+	}
+
+	rows.index = index
+	rows.file = f
+	rows.reader = parquet.NewReader(file)
+	rows.next, rows.n = 0, 0
+	return nil
+}
+
+func (rows *parquetRows) Columns() []connector.Column {
+	columns := make([]connector.Column, len(rows.columns))
+	for i, column := range rows.columns {
+		columns[i] = connector.Column{Name: column.Name, Type: parquetSQLType(column.Leaf)}
+	}
+	return columns
+}
+
+func (rows *parquetRows) Next() bool {
+	for rows.reader != nil {
+		if rows.next < rows.n {
+			values, err := rows.rowValues(rows.buf[rows.next])
+			rows.next++
+			if err != nil {
+				rows.err = err
+				return false
+			}
+			rows.values = values
+			return true
+		}
+
+		n, err := rows.reader.ReadRows(rows.buf)
+		rows.next, rows.n = 0, n
+		if n > 0 && (err == nil || err == io.EOF) {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			rows.err = fmt.Errorf("read %s: %w", rows.files[rows.index].Name, err)
+			return false
+		}
+		rows.closeFile()
+		if rows.index+1 < len(rows.files) {
+			rows.err = rows.openFile(rows.index + 1)
+		}
+	}
+	return false
+}
+
+func (rows *parquetRows) rowValues(row parquet.Row) ([]interface{}, error) {
+	values := make([]interface{}, len(rows.columns))
+	lists := make([][]interface{}, len(rows.columns))
+	for _, v := range row {
+		i, ok := rows.byIndex[v.Column()]
+		if !ok {
+			continue
+		}
+		column := rows.columns[i]
+		if column.Leaf.MaxRepetitionLevel > 0 {
+			if !v.IsNull() {
+				lists[i] = append(lists[i], parquetValue(v, column.Leaf.Node.Type()))
+			}
+			continue
+		}
+		values[i] = parquetValue(v, column.Leaf.Node.Type())
+	}
+	// Repeated values are stored as JSON arrays.
+	for i, list := range lists {
+		if list == nil {
+			continue
+		}
+		b, err := json.Marshal(list)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = string(b)
+	}
+	return values, nil
+}
+
+func (rows *parquetRows) Values() []interface{} { return rows.values }
+
+func (rows *parquetRows) Err() error { return rows.err }
+
+func (rows *parquetRows) Close() error {
+	rows.closeFile()
+	return nil
+}
+
+func (rows *parquetRows) closeFile() {
+	if rows.reader != nil {
+		rows.reader.Close()
+		rows.file.Close()
+		rows.file, rows.reader = nil, nil
+	}
+}
+
+func parquetColumns(schema *parquet.Schema) []parquetColumn {
+	columns := []parquetColumn{}
+	for _, path := range schema.Columns() {
+		leaf, _ := schema.Lookup(path...)
+		// Lists are stored as <name>.list.element, so use the name of
+		// the list instead.
+		name := path
+		if n := len(name); n >= 3 && name[n-2] == "list" && (name[n-1] == "element" || name[n-1] == "item") {
+			name = name[:n-2]
+		}
+		columns = append(columns, parquetColumn{
+			Name: strings.Join(name, "."),
+			Leaf: leaf,
+		})
+	}
+	return columns
+}
+
+func sameParquetColumns(a, b []parquetColumn) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name ||
+			a[i].Leaf.MaxRepetitionLevel != b[i].Leaf.MaxRepetitionLevel ||
+			parquetSQLType(a[i].Leaf) != parquetSQLType(b[i].Leaf) {
+			return false
+		}
+	}
+	return true
+}
+
+// parquetSQLType returns the SQLite column type for a Parquet column.
+func parquetSQLType(leaf parquet.LeafColumn) string {
+	if leaf.MaxRepetitionLevel > 0 {
+		return "TEXT"
+	}
+	typ := leaf.Node.Type()
+	if lt := typ.LogicalType(); lt != nil {
+		switch {
+		case lt.Date != nil, lt.Time != nil, lt.Timestamp != nil, lt.UTF8 != nil,
+			lt.Enum != nil, lt.Json != nil, lt.UUID != nil,
+			lt.Decimal != nil:
+			return "TEXT"
+		}
+	}
+	switch typ.Kind() {
+	case parquet.Boolean, parquet.Int32, parquet.Int64:
+		return "INTEGER"
+	case parquet.Float, parquet.Double:
+		return "REAL"
+	case parquet.Int96:
+		return "TEXT"
+	default:
+		return "BLOB"
+	}
+}
+
+// parquetValue converts a Parquet value into a value that can be stored
+// in SQLite. Dates and times are stored as ISO 8601 text.
+func parquetValue(v parquet.Value, typ parquet.Type) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+	if lt := typ.LogicalType(); lt != nil {
+		switch {
+		case lt.Date != nil:
+			return time.Unix(int64(v.Int32())*86400, 0).UTC().Format("2006-01-02")
+		case lt.Timestamp != nil:
+			return time.Unix(0, 0).Add(parquetDuration(v.Int64(), lt.Timestamp.Unit.Millis != nil, lt.Timestamp.Unit.Micros != nil)).
+				UTC().Format(time.RFC3339Nano)
+		case lt.Time != nil:
+			var t int64
+			if v.Kind() == parquet.Int32 {
+				t = int64(v.Int32())
+			} else {
+				t = v.Int64()
+			}
+			return time.Time{}.Add(parquetDuration(t, lt.Time.Unit.Millis != nil, lt.Time.Unit.Micros != nil)).
+				Format("15:04:05.999999999")
+		case lt.Decimal != nil:
+			return parquetDecimal(v, int(lt.Decimal.Scale))
+		case lt.UTF8 != nil, lt.Enum != nil, lt.Json != nil:
+			return string(v.ByteArray())
+		case lt.UUID != nil:
+			id, err := uuid.FromBytes(v.ByteArray())
+			if err == nil {
+				return id.String()
+			}
+		case lt.Integer != nil && !lt.Integer.IsSigned:
+			if v.Kind() == parquet.Int32 {
+				return int64(uint32(v.Int32()))
+			}
+			if u := uint64(v.Int64()); u > math.MaxInt64 {
+				return float64(u)
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32:
+		return int64(v.Int32())
+	case parquet.Int64:
+		return v.Int64()
+	case parquet.Int96:
+		// Legacy timestamps are nanoseconds within a Julian day.
+		i := v.Int96()
+		nanos := int64(uint64(i[1])<<32 | uint64(i[0]))
+		days := int64(i[2]) - 2440588
+		return time.Unix(days*86400, nanos).UTC().Format(time.RFC3339Nano)
+	case parquet.Float:
+		return float64(v.Float())
+	case parquet.Double:
+		return v.Double()
+	default:
+		// The value's memory is reused by the reader.
+		return append([]byte{}, v.ByteArray()...)
+	}
+}
+
+func parquetDuration(t int64, millis, micros bool) time.Duration {
+	switch {
+	case millis:
+		return time.Duration(t) * time.Millisecond
+	case micros:
+		return time.Duration(t) * time.Microsecond
+	default:
+		return time.Duration(t)
+	}
+}
+
+// parquetDecimal returns the exact value of a decimal as text, since it
+// might not be representable as a float.
+func parquetDecimal(v parquet.Value, scale int) string {
+	unscaled := new(big.Int)
+	switch v.Kind() {
+	case parquet.Int32:
+		unscaled.SetInt64(int64(v.Int32()))
+	case parquet.Int64:
+		unscaled.SetInt64(v.Int64())
+	default:
+		// Big-endian two's complement
+		b := v.ByteArray()
+		unscaled.SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+		}
+	}
+	digits := new(big.Int).Abs(unscaled).String()
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if unscaled.Sign() < 0 {
+		digits = "-" + digits
+	}
+	return digits
+}
+
+// writeParquet writes a table as a Parquet file. Column types are chosen
+// from the values stored in each column, since SQLite columns don't
+// need to have a type.
+func writeParquet(w io.Writer, db *sql.DB, table string) error {
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s", quoteIdentifier(table)))
+	if err != nil {
+		return err
+	}
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	kinds := make([]parquet.Kind, len(columns))
+	nodes := make([]parquet.Node, len(columns))
+	for i, column := range columns {
+		kinds[i], nodes[i], err = parquetColumnType(db, table, column)
+		if err != nil {
+			return err
+		}
+	}
+	schema := parquet.NewSchema(table, orderedGroup{names: columns, nodes: nodes})
+	writer := parquet.NewWriter(w, schema)
+
+	// The unary + drops the declared types of the columns, so that the
+	// driver returns the stored values instead of converting those of
+	// BOOLEAN, DATE and TIMESTAMP columns.
+	selected := make([]string, len(columns))
+	for i, column := range columns {
+		selected[i] = "+" + quoteIdentifier(column)
+	}
+	rows, err = db.Query(fmt.Sprintf("SELECT %s FROM %s", strings.Join(selected, ","), quoteIdentifier(table)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cols := make([]interface{}, len(columns))
+		colPointers := make([]interface{}, len(cols))
+		for i := range cols {
+			colPointers[i] = &cols[i]
+		}
+		if err := rows.Scan(colPointers...); err != nil {
+			return err
+		}
+
+		row := make(parquet.Row, len(columns))
+		for i, val := range cols {
+			if val == nil {
+				row[i] = parquet.Value{}.Level(0, 0, i)
+				continue
+			}
+			switch kinds[i] {
+			case parquet.Int64:
+				switch v := val.(type) {
+				case int64:
+					row[i] = parquet.Int64Value(v)
+				case bool:
+					if v {
+						row[i] = parquet.Int64Value(1)
+					} else {
+						row[i] = parquet.Int64Value(0)
+					}
+				}
+			case parquet.Double:
+				switch v := val.(type) {
+				case int64:
+					row[i] = parquet.DoubleValue(float64(v))
+				case float64:
+					row[i] = parquet.DoubleValue(v)
+				}
+			default:
+				switch v := val.(type) {
+				case []byte:
+					row[i] = parquet.ByteArrayValue(v)
+				case string:
+					row[i] = parquet.ByteArrayValue([]byte(v))
+				default:
+					row[i] = parquet.ByteArrayValue([]byte(fmt.Sprint(v)))
+				}
+			}
+			row[i] = row[i].Level(0, 1, i)
+		}
+		_, err = writer.WriteRows([]parquet.Row{row})
+		if err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// parquetColumnType chooses the Parquet type of a column based on the
+// storage classes of its values.
+func parquetColumnType(db *sql.DB, table, column string) (parquet.Kind, parquet.Node, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT DISTINCT typeof(%s) FROM %s", quoteIdentifier(column), quoteIdentifier(table)))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	storageClasses := map[string]bool{}
+	for rows.Next() {
+		storageClass := ""
+		if err := rows.Scan(&storageClass); err != nil {
+			return 0, nil, err
+		}
+		if storageClass != "null" {
+			storageClasses[storageClass] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	switch {
+	case len(storageClasses) == 1 && storageClasses["integer"]:
+		return parquet.Int64, parquet.Optional(parquet.Leaf(parquet.Int64Type)), nil
+	case len(storageClasses) > 0 && !storageClasses["text"] && !storageClasses["blob"]:
+		return parquet.Double, parquet.Optional(parquet.Leaf(parquet.DoubleType)), nil
+	case len(storageClasses) == 1 && storageClasses["blob"]:
+		return parquet.ByteArray, parquet.Optional(parquet.Leaf(parquet.ByteArrayType)), nil
+	default:
+		return parquet.ByteArray, parquet.Optional(parquet.String()), nil
+	}
+}
+
+// orderedGroup is a Parquet group that keeps the order of its fields,
+// unlike parquet.Group which sorts them by name.
+type orderedGroup struct {
+	parquet.Group
+	names []string
+	nodes []parquet.Node
+}
+
+func (g orderedGroup) Fields() []parquet.Field {
+	fields := make([]parquet.Field, len(g.names))
+	for i := range g.names {
+		fields[i] = orderedGroupField{Node: g.nodes[i], name: g.names[i]}
+	}
+	return fields
+}
+
+type orderedGroupField struct {
+	parquet.Node
+	name string
+}
+
+func (f orderedGroupField) Name() string { return f.name }
+
+func (f orderedGroupField) Value(base reflect.Value) reflect.Value {
+	return reflect.Value{}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetRoundTrip(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "dataset.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE orders (id INTEGER, amount REAL, region TEXT, returned BOOLEAN, day DATE);
+	INSERT INTO orders VALUES
+		(1, 9.5, 'West', 1, '2024-01-02'),
+		(2, 10, 'East', 0, NULL),
+		(NULL, NULL, NULL, NULL, '2024-01-03')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = writeParquet(buf, db, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := openParquet([]dataFile{{
+		Name: "orders.parquet",
+		Open: func() (dataFileReader, int64, error) {
+			return bytesFile{bytes.NewReader(buf.Bytes())}, int64(buf.Len()), nil
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns := []string{}
+	for _, column := range rows.Columns() {
+		columns = append(columns, column.Name)
+	}
+	if expected := []string{"id", "amount", "region", "returned", "day"}; !reflect.DeepEqual(columns, expected) {
+		t.Errorf("expected columns %v, got %v", expected, columns)
+	}
+
+	records := [][]interface{}{}
+	for rows.Next() {
+		records = append(records, rows.Values())
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]interface{}{
+		{int64(1), 9.5, "West", int64(1), "2024-01-02"},
+		{int64(2), 10.0, "East", int64(0), nil},
+		{nil, nil, nil, nil, "2024-01-03"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
+
+func TestParquetDecimal(t *testing.T) {
+	type order struct {
+		Amount int64    `parquet:"amount,decimal(2:18)"`
+		Total  [16]byte `parquet:"total,decimal(4:38)"`
+	}
+	// -1 in 16 bytes of two's complement.
+	minusOne := [16]byte{}
+	for i := range minusOne {
+		minusOne[i] = 0xff
+	}
+	buf := &bytes.Buffer{}
+	err := parquet.Write(buf, []order{
+		{Amount: 1234567890123456789, Total: [16]byte{15: 1}},
+		{Amount: -5, Total: minusOne},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := openParquet([]dataFile{{
+		Name: "orders.parquet",
+		Open: func() (dataFileReader, int64, error) {
+			return bytesFile{bytes.NewReader(buf.Bytes())}, int64(buf.Len()), nil
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	for _, column := range rows.Columns() {
+		if column.Type != "TEXT" {
+			t.Errorf("expected column `%s` to be TEXT, got %s", column.Name, column.Type)
+		}
+	}
+	records := [][]interface{}{}
+	for rows.Next() {
+		records = append(records, rows.Values())
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	// 12345678901234567.89 can't be represented exactly as a float64.
+	expected := [][]interface{}{
+		{"12345678901234567.89", "0.0001"},
+		{"-0.05", "-0.0001"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
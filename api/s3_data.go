@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/crossjoin-io/crossjoin/config"
+	"github.com/crossjoin-io/crossjoin/connector"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const defaultS3Endpoint = "s3.amazonaws.com"
+
+// s3Connector reads data connections of objects in S3-compatible object
+// storage.
+type s3Connector struct {
+	config.DataConnectionValidator
+}
+
+func (s3Connector) Open(ctx context.Context, dataConnection *config.DataConnection, dataSource *config.DataSource) (connector.Rows, error) {
+	files, err := s3DataFiles(ctx, dataConnection)
+	if err != nil {
+		return nil, err
+	}
+	return openFiles(files, dataConnection.FileFormat(), dataConnection, dataSource)
+}
+
+func newS3Client(dataConnection *config.DataConnection) (*minio.Client, error) {
+	endpoint := dataConnection.Endpoint
+	if endpoint == "" {
+		endpoint = defaultS3Endpoint
+	}
+
+	var creds *credentials.Credentials
+	if dataConnection.AccessKey != "" {
+		creds = credentials.NewStaticV4(
+			os.ExpandEnv(dataConnection.AccessKey),
+			os.ExpandEnv(dataConnection.SecretKey),
+			os.ExpandEnv(dataConnection.SessionToken),
+		)
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		})
+	}
+
+	return minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !dataConnection.DisableSSL,
+		Region: dataConnection.Region,
+	})
+}
+
+// s3DataFiles returns the object of an s3 connection, or the objects of
+// its format under the prefix.
+func s3DataFiles(ctx context.Context, dataConnection *config.DataConnection) ([]dataFile, error) {
+	client, err := newS3Client(dataConnection)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	if dataConnection.Key != "" {
+		keys = append(keys, dataConnection.Key)
+	} else {
+		extensions := config.FileExtensions[dataConnection.FileFormat()]
+		// Canceling the listing stops its goroutine if the loop returns
+		// early. The objects are read later with ctx.
+		listCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		for object := range client.ListObjects(listCtx, dataConnection.Bucket, minio.ListObjectsOptions{
+			Prefix:    dataConnection.Prefix,
+			Recursive: true,
+		}) {
+			if object.Err != nil {
+				return nil, fmt.Errorf("list objects: %w", object.Err)
+			}
+			name := path.Base(object.Key)
+			if strings.HasSuffix(object.Key, "/") || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") ||
+				!hasExtension(name, extensions) {
+				continue
+			}
+			keys = append(keys, object.Key)
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no %s objects found in `s3://%s/%s`", dataConnection.FileFormat(), dataConnection.Bucket, dataConnection.Prefix)
+		}
+		sort.Strings(keys)
+	}
+
+	files := []dataFile{}
+	for _, key := range keys {
+		key := key
+		name := fmt.Sprintf("s3://%s/%s", dataConnection.Bucket, key)
+		files = append(files, dataFile{
+			Name: name,
+			Open: func() (dataFileReader, int64, error) {
+				log.Printf("reading object `%s`", name)
+				object, err := client.GetObject(ctx, dataConnection.Bucket, key, minio.GetObjectOptions{})
+				if err != nil {
+					return nil, 0, fmt.Errorf("get object: %w", err)
+				}
+				info, err := object.Stat()
+				if err != nil {
+					object.Close()
+					return nil, 0, fmt.Errorf("get object `%s`: %w", name, err)
+				}
+				return object, info.Size, nil
+			},
+		})
+	}
+	return files, nil
+}
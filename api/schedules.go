@@ -0,0 +1,101 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/crossjoin-io/crossjoin/config"
+)
+
+const (
+	// Scheduled times older than this when they are noticed were missed,
+	// e.g. because the server was down.
+	scheduleGracePeriod = time.Minute
+	// maxScheduleCatchUp limits how many missed runs are started at once.
+	maxScheduleCatchUp = 1000
+)
+
+// RunWorkflowSchedules starts workflow runs for schedules that are due.
+// The last time each schedule fired is stored in the database so restarts
+// don't skip or repeat runs.
+func (api *API) RunWorkflowSchedules(hash string, now time.Time) error {
+	workflows, err := api.GetWorkflows(hash)
+	if err != nil {
+		return fmt.Errorf("get workflows: %w", err)
+	}
+	for _, workflow := range workflows {
+		if workflow.On == nil {
+			continue
+		}
+		for _, schedule := range workflow.On.Schedule {
+			err = api.runWorkflowSchedule(hash, workflow.ID, schedule, now)
+			if err != nil {
+				return fmt.Errorf("workflow %s schedule `%s`: %w", workflow.ID, schedule.Cron, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (api *API) runWorkflowSchedule(hash, workflowID string, schedule config.Schedule, now time.Time) error {
+	cronSchedule, err := config.ParseCron(schedule.Cron, schedule.Timezone)
+	if err != nil {
+		return err
+	}
+
+	var lastFiredAt time.Time
+	err = api.db.QueryRow("SELECT last_fired_at FROM workflow_schedules WHERE workflow_id = $1 AND schedule = $2",
+		workflowID, schedule.Key()).Scan(&lastFiredAt)
+	if err == sql.ErrNoRows {
+		// New schedules start counting from now.
+		_, err = api.db.Exec("INSERT INTO workflow_schedules (workflow_id, schedule, last_fired_at) VALUES ($1, $2, $3)",
+			workflowID, schedule.Key(), now.UTC())
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("query last fired time: %w", err)
+	}
+
+	due := []time.Time{}
+	for t := cronSchedule.Next(lastFiredAt); !t.After(now) && len(due) < maxScheduleCatchUp; t = cronSchedule.Next(t) {
+		due = append(due, t)
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	runs := due
+	switch schedule.CatchUp {
+	case "", "skip":
+		runs = nil
+		if latest := due[len(due)-1]; now.Sub(latest) <= scheduleGracePeriod {
+			runs = []time.Time{latest}
+		}
+	case "once":
+		runs = due[len(due)-1:]
+	}
+	if missed := len(due) - len(runs); missed > 0 {
+		log.Printf("skipping %d missed runs of workflow %s", missed, workflowID)
+	}
+
+	// Store the last fired time before starting any runs so that a
+	// failure part way through doesn't start the same runs again.
+	_, err = api.db.Exec("UPDATE workflow_schedules SET last_fired_at = $1 WHERE workflow_id = $2 AND schedule = $3",
+		due[len(due)-1].UTC(), workflowID, schedule.Key())
+	if err != nil {
+		return fmt.Errorf("update last fired time: %w", err)
+	}
+
+	for _, scheduledAt := range runs {
+		log.Printf("starting workflow %s scheduled at %s", workflowID, scheduledAt)
+		err = api.StartWorkflow(hash, workflowID, map[string]interface{}{
+			"scheduled_at": scheduledAt.Format(time.RFC3339),
+		})
+		if err != nil {
+			return fmt.Errorf("start workflow: %w", err)
+		}
+	}
+	return nil
+}
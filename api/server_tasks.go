@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/crossjoin-io/crossjoin/config"
+)
+
+type serverTask struct {
+	ID             string
+	AttemptID      string
+	ConfigHash     string
+	WorkflowRunID  string
+	WorkflowTaskID string
+	Type           string
+	Input          map[string]interface{}
+	Def            *config.WorkflowTask
+}
+
+// RunServerTasks claims tasks that are executed by the server instead of
+// runners and starts them.
+func (api *API) RunServerTasks() error {
+	rows, err := api.db.Query(`select tasks.id, workflow_runs.config_hash, workflow_run_id, workflow_task_id, type, tasks.input
+		from tasks join workflow_runs on workflow_runs.id = tasks.workflow_run_id where
+		type != 'container' and
+		tasks.completed_at is null and
+		(run_at is null or run_at <= datetime('now')) and
+		attempts_left > 0 and
+		(tasks.started_at is null or timeout_at < datetime('now')) and
+		workflow_runs.completed_at is null`)
+	if err != nil {
+		return fmt.Errorf("query server tasks: %w", err)
+	}
+	tasks := []serverTask{}
+	for rows.Next() {
+		t := serverTask{}
+		var input []byte
+		err = rows.Scan(&t.ID, &t.ConfigHash, &t.WorkflowRunID, &t.WorkflowTaskID, &t.Type, &input)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("scan server task: %w", err)
+		}
+		err = json.Unmarshal(input, &t.Input)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("unmarshal task input: %w", err)
+		}
+		tasks = append(tasks, t)
+	}
+	rows.Close()
+
+	for _, t := range tasks {
+		workflow, err := api.GetWorkflowFromWorkflowRunID(t.WorkflowRunID)
+		if err != nil {
+			return fmt.Errorf("get workflow: %w", err)
+		}
+		t.Def = workflow.Tasks[t.WorkflowTaskID]
+		if t.Def == nil {
+			return fmt.Errorf("unknown task `%s`", t.WorkflowTaskID)
+		}
+
+		_, err = api.db.Exec("update tasks set started_at = datetime('now'), "+
+			"timeout_at = datetime('now', $1), "+
+			"attempts_left = attempts_left-1 "+
+			"where id = $2", sqliteModifier(t.Def.TimeoutDuration()), t.ID)
+		if err != nil {
+			return fmt.Errorf("mark task as started: %w", err)
+		}
+		t.AttemptID, err = startTaskAttempt(api.db, t.ID, "server")
+		if err != nil {
+			return err
+		}
+
+		go api.runServerTask(t)
+	}
+	return nil
+}
+
+func (api *API) runServerTask(t serverTask) {
+	log.Printf("running %s task %s", t.Type, t.ID)
+	result := TaskResult{
+		ID:        t.ID,
+		AttemptID: t.AttemptID,
+	}
+	var err error
+	switch t.Type {
+	case "delay":
+		// The delay has already passed by the time the task is claimed,
+		// so the input is passed through to the next task.
+		result.OK = true
+		result.Output = t.Input
+	case "http":
+		err = runHTTPTask(t, &result)
+	case "sql":
+		err = api.runSQLTask(t, &result)
+	default:
+		err = fmt.Errorf("unknown task type `%s`", t.Type)
+	}
+	if err != nil {
+		log.Println(fmt.Errorf("%s task %s: %w", t.Type, t.ID, err))
+		result.OK = false
+		result.Stderr = err.Error()
+	}
+
+	err = api.CompleteTask(result)
+	if err != nil {
+		log.Println(fmt.Errorf("complete task %s: %w", t.ID, err))
+	}
+}
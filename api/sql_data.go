@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crossjoin-io/crossjoin/config"
+	"github.com/crossjoin-io/crossjoin/connector"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+// sqlConnector reads data sources that are queries of a database.
+type sqlConnector struct {
+	config.DataConnectionValidator
+}
+
+func (c sqlConnector) Open(ctx context.Context, dataConnection *config.DataConnection, dataSource *config.DataSource) (connector.Rows, error) {
+	return c.query(ctx, dataConnection, dataSource.Query)
+}
+
+// OpenIncremental runs the query of a data source with the cursor as its
+// parameter.
+func (c sqlConnector) OpenIncremental(ctx context.Context, dataConnection *config.DataConnection, dataSource *config.DataSource, cursor interface{}) (connector.Rows, error) {
+	return c.query(ctx, dataConnection, dataSource.Query, cursor)
+}
+
+func (sqlConnector) query(ctx context.Context, dataConnection *config.DataConnection, query string, args ...interface{}) (connector.Rows, error) {
+	db, err := openDataConnection(dataConnection)
+	if err != nil {
+		return nil, err
+	}
+	return queryRows(ctx, db, query, args...)
+}
+
+// queryRows runs a query and returns its rows, which close the database
+// once they're closed.
+func queryRows(ctx context.Context, db *sql.DB, query string, args ...interface{}) (connector.Rows, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		rows.Close()
+		db.Close()
+		return nil, err
+	}
+	return &sqlRows{db: db, rows: rows, columnTypes: columnTypes}, nil
+}
+
+// openDataConnection opens a database for a queryable data connection.
+// SQLite files are opened read-only.
+func openDataConnection(dataConnection *config.DataConnection) (*sql.DB, error) {
+	switch dataConnection.Type {
+	case "sqlite":
+		urlPath, err := url.Parse(dataConnection.Path)
+		if err == nil && urlPath.Scheme != "" {
+			return nil, fmt.Errorf("sqlite data connection `%s` must be a local file", dataConnection.ID)
+		}
+		if _, err := os.Stat(dataConnection.Path); err != nil {
+			return nil, err
+		}
+		log.Printf("opening file `%s`", dataConnection.Path)
+		return sql.Open("sqlite3", "file:"+dataConnection.Path+"?mode=ro")
+	default:
+		return sql.Open(dataConnection.Type, dataConnection.ConnectionString)
+	}
+}
+
+type sqlRows struct {
+	db          *sql.DB
+	rows        *sql.Rows
+	columnTypes []*sql.ColumnType
+	values      []interface{}
+	err         error
+}
+
+func (rows *sqlRows) Columns() []connector.Column {
+	columns := make([]connector.Column, len(rows.columnTypes))
+	for i, columnType := range rows.columnTypes {
+		columns[i] = connector.Column{Name: columnType.Name(), Type: sqlColumnType(columnType)}
+	}
+	return columns
+}
+
+func (rows *sqlRows) Next() bool {
+	if !rows.rows.Next() {
+		return false
+	}
+	values := make([]interface{}, len(rows.columnTypes))
+	pointers := make([]interface{}, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.rows.Scan(pointers...); err != nil {
+		rows.err = err
+		return false
+	}
+	for i, val := range values {
+		// Drivers like MySQL's scan most values as []byte, which
+		// would be stored as blobs. Only binary columns should be.
+		if b, ok := val.([]byte); ok && !isBinaryColumn(rows.columnTypes[i]) {
+			values[i] = string(b)
+		}
+		// Dates are scanned as times at midnight.
+		if t, ok := val.(time.Time); ok && sqlColumnType(rows.columnTypes[i]) == "DATE" {
+			values[i] = t.Format("2006-01-02")
+		}
+	}
+	rows.values = values
+	return true
+}
+
+func (rows *sqlRows) Values() []interface{} { return rows.values }
+
+func (rows *sqlRows) Err() error {
+	if rows.err != nil {
+		return rows.err
+	}
+	return rows.rows.Err()
+}
+
+func (rows *sqlRows) Close() error {
+	rows.rows.Close()
+	return rows.db.Close()
+}
+
+func isBinaryColumn(columnType *sql.ColumnType) bool {
+	switch strings.ToUpper(columnType.DatabaseTypeName()) {
+	case "BYTEA", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BIT", "GEOMETRY":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/crossjoin-io/crossjoin/config"
+)
+
+// maxSQLTaskRows limits the rows returned by a "sql" task, since they're
+// stored in the task output and passed to the next tasks.
+const maxSQLTaskRows = 10000
+
+// runSQLTask runs the query of a "sql" task against a dataset or a data
+// connection. Datasets are opened read-only. The task's args are read
+// from the task input and bound as query parameters.
+func (api *API) runSQLTask(t serverTask, result *TaskResult) error {
+	var (
+		db  *sql.DB
+		err error
+	)
+	if t.Def.Dataset != "" {
+		var versions map[string]string
+		versions, err = api.workflowRunDatasetVersions(t.WorkflowRunID)
+		if err != nil {
+			return fmt.Errorf("read dataset versions: %w", err)
+		}
+		filename := api.datasetFilename(t.Def.Dataset, versions[t.Def.Dataset])
+		db, err = sql.Open("sqlite3", "file:"+filename+"?mode=ro")
+	} else {
+		var dataConnection *config.DataConnection
+		dataConnection, err = api.ReadDataConnection(t.ConfigHash, t.Def.DataConnection)
+		if err != nil {
+			return fmt.Errorf("read data connection: %w", err)
+		}
+		db, err = openDataConnection(dataConnection)
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	args := []interface{}{}
+	for _, key := range t.Def.Args {
+		value, ok := t.Input[key]
+		if !ok {
+			return fmt.Errorf("missing input `%s`", key)
+		}
+		args = append(args, value)
+	}
+
+	if !config.IsSQLQuery(t.Def.Query) {
+		res, err := db.Exec(t.Def.Query, args...)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		result.OK = true
+		result.Output = map[string]interface{}{
+			"rows_affected": rowsAffected,
+		}
+		return nil
+	}
+
+	rows, err := db.Query(t.Def.Query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	resultRows := []interface{}{}
+	for rows.Next() {
+		if len(resultRows) == maxSQLTaskRows {
+			return fmt.Errorf("query returned more than %d rows", maxSQLTaskRows)
+		}
+		values := make([]interface{}, len(columns))
+		valPointers := make([]interface{}, len(values))
+		for i := range values {
+			valPointers[i] = &values[i]
+		}
+		err = rows.Scan(valPointers...)
+		if err != nil {
+			return err
+		}
+		row := map[string]interface{}{}
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[col] = values[i]
+		}
+		resultRows = append(resultRows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	result.OK = true
+	result.Output = map[string]interface{}{
+		"rows":      resultRows,
+		"row_count": len(resultRows),
+	}
+	return nil
+}
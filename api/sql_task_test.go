@@ -0,0 +1,59 @@
+package api
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crossjoin-io/crossjoin/config"
+)
+
+func TestSQLTaskRowLimit(t *testing.T) {
+	api := newTestAPI(t, `
+data_connections:
+  - id: numbers
+    type: sqlite
+    path: ./numbers.db
+`)
+	db, err := sql.Open("sqlite3", filepath.Join(api.dataDir, "numbers.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE numbers (n INTEGER)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := api.LatestConfigHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(limit int) (TaskResult, error) {
+		result := TaskResult{}
+		err := api.runSQLTask(serverTask{
+			ConfigHash: hash,
+			Input:      map[string]interface{}{"limit": limit},
+			Def: &config.WorkflowTask{
+				Type:           "sql",
+				DataConnection: "numbers",
+				Query: `WITH RECURSIVE numbers(n) AS (SELECT 1 UNION ALL SELECT n+1 FROM numbers WHERE n < $1)
+				SELECT n FROM numbers`,
+				Args: []string{"limit"},
+			},
+		}, &result)
+		return result, err
+	}
+
+	result, err := run(maxSQLTaskRows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK || result.Output["row_count"] != maxSQLTaskRows {
+		t.Errorf("expected %d rows, got %v", maxSQLTaskRows, result.Output["row_count"])
+	}
+	_, err = run(maxSQLTaskRows + 1)
+	if err == nil || !strings.Contains(err.Error(), "more than") {
+		t.Errorf("expected an error for too many rows, got %v", err)
+	}
+}
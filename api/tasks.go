@@ -0,0 +1,335 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/crossjoin-io/crossjoin/config"
+	"github.com/google/uuid"
+)
+
+// errStaleAttempt is returned for results of attempts that were already
+// closed, which are ignored.
+var errStaleAttempt = errors.New("stale attempt")
+
+// CompleteTask stores the result of a task and schedules the tasks
+// that depend on it. The workflow run is completed once there are
+// no tasks left to run.
+func (api *API) CompleteTask(result TaskResult) error {
+	// Completing a task and scheduling its children has to be atomic
+	// with respect to other tasks in the same run finishing. Otherwise
+	// a run could be marked as complete while a sibling is still
+	// scheduling its children.
+	api.tasksMu.Lock()
+	defer api.tasksMu.Unlock()
+
+	if result.Output == nil {
+		result.Output = map[string]interface{}{}
+	}
+	marshaledOutput, err := json.Marshal(result.Output)
+	if err != nil {
+		return fmt.Errorf("marshal output: %w", err)
+	}
+
+	workflowRunID := ""
+	workflowTaskID := ""
+	attemptsLeft := 0
+	taskCompleted := false
+	err = api.db.QueryRow("select workflow_run_id, workflow_task_id, attempts_left, completed_at is not null from tasks where id = $1", result.ID).
+		Scan(&workflowRunID, &workflowTaskID, &attemptsLeft, &taskCompleted)
+	if err != nil {
+		return fmt.Errorf("query task: %w", err)
+	}
+	// The task was already completed, e.g. because it timed out.
+	if taskCompleted {
+		return fmt.Errorf("task %s: %w", result.ID, errStaleAttempt)
+	}
+
+	err = api.completeTaskAttempt(result, marshaledOutput)
+	if err != nil {
+		return err
+	}
+	hash := ""
+	workflowID := ""
+	runCompleted := false
+	err = api.db.QueryRow("select config_hash, workflow_id, completed_at is not null from workflow_runs where id = $1", workflowRunID).
+		Scan(&hash, &workflowID, &runCompleted)
+	if err != nil {
+		return fmt.Errorf("query workflow run: %w", err)
+	}
+	workflow, err := api.GetWorkflow(hash, workflowID)
+	if err != nil {
+		return fmt.Errorf("get workflow: %w", err)
+	}
+	taskDef := workflow.Tasks[workflowTaskID]
+
+	// If the task failed but has attempts left, put it back in the
+	// queue after the retry backoff instead of completing it.
+	if !result.OK && !runCompleted && attemptsLeft > 0 && taskDef != nil {
+		retryDelay := taskDef.RetryDelay(taskDef.Attempts() - attemptsLeft)
+		log.Printf("task %s failed; retrying in %s (%d attempts left)", result.ID, retryDelay, attemptsLeft)
+		_, err = api.db.Exec("update tasks set started_at = null, timeout_at = null, run_at = datetime('now', $1), "+
+			"success = $2, output = $3, stdout = $4, stderr = $5 where id = $6",
+			sqliteModifier(retryDelay), result.OK, marshaledOutput, result.Stdout, result.Stderr, result.ID)
+		if err != nil {
+			return fmt.Errorf("update task: %w", err)
+		}
+		return nil
+	}
+
+	_, err = api.db.Exec("update tasks set completed_at = datetime('now'), success = $1, output = $2, stdout = $3, stderr = $4 where id = $5",
+		result.OK, marshaledOutput, result.Stdout, result.Stderr, result.ID)
+	if err != nil {
+		return fmt.Errorf("update task: %w", err)
+	}
+
+	// Another branch of the workflow already failed the run.
+	if runCompleted {
+		return nil
+	}
+
+	// If the task wasn't a success, fail the workflow run.
+	if !result.OK {
+		return api.CompleteWorkflowRun(workflowRunID, false)
+	}
+
+	// The chosen branch is stored, so that branch targets that weren't
+	// chosen are skipped even if they have other parents.
+	if taskDef != nil && len(taskDef.Branches) > 0 {
+		branch, err := taskDef.ChooseBranch(result.Output)
+		if err != nil {
+			log.Println(fmt.Errorf("choose branch for task %s: %w", result.ID, err))
+			return api.CompleteWorkflowRun(workflowRunID, false)
+		}
+		if branch != nil && branch.End {
+			return api.CompleteWorkflowRun(workflowRunID, true)
+		}
+		var chosen *string
+		if branch != nil {
+			chosen = &branch.Next
+		}
+		_, err = api.db.Exec("update tasks set chosen_branch = $1 where id = $2", chosen, result.ID)
+		if err != nil {
+			return fmt.Errorf("update task: %w", err)
+		}
+	}
+
+	for _, child := range workflow.Children(workflowTaskID) {
+		input, ready, skip, err := api.childTaskInput(workflowRunID, workflow, child)
+		if err != nil {
+			return err
+		}
+		if skip {
+			err = api.skipTask(workflowRunID, workflow, child)
+			if err != nil {
+				return fmt.Errorf("skip task: %w", err)
+			}
+			continue
+		}
+		if !ready {
+			continue
+		}
+		err = api.ScheduleTask(workflowRunID, child, input)
+		if err != nil {
+			return fmt.Errorf("schedule task: %w", err)
+		}
+	}
+
+	remaining := 0
+	err = api.db.QueryRow("select count(*) from tasks where workflow_run_id = $1 and completed_at is null", workflowRunID).
+		Scan(&remaining)
+	if err != nil {
+		return fmt.Errorf("count remaining tasks: %w", err)
+	}
+	if remaining == 0 {
+		// End of the workflow
+		return api.CompleteWorkflowRun(workflowRunID, true)
+	}
+	return nil
+}
+
+// childTaskInput checks if all of the parents of a task have completed
+// successfully, and if so returns their merged outputs. Outputs of parents
+// are merged in order of their IDs, so later IDs take precedence. The task
+// is skipped if a parent was skipped or branched to another task.
+func (api *API) childTaskInput(workflowRunID string, workflow *config.Workflow, workflowTaskID string) (map[string]interface{}, bool, bool, error) {
+	input := map[string]interface{}{}
+	ready := true
+	for _, parent := range workflow.Parents(workflowTaskID) {
+		var output []byte
+		success := false
+		skipped := false
+		var chosenBranch sql.NullString
+		err := api.db.QueryRow(`select output, coalesce(success, 0), skipped, chosen_branch from tasks
+		where workflow_run_id = $1 and workflow_task_id = $2 and completed_at is not null`,
+			workflowRunID, parent).Scan(&output, &success, &skipped, &chosenBranch)
+		if err == sql.ErrNoRows {
+			ready = false
+			continue
+		}
+		if err != nil {
+			return nil, false, false, fmt.Errorf("query parent task: %w", err)
+		}
+		if skipped || (isBranchEdge(workflow, parent, workflowTaskID) && chosenBranch.String != workflowTaskID) {
+			return nil, false, true, nil
+		}
+		if !success {
+			ready = false
+			continue
+		}
+		parentOutput := map[string]interface{}{}
+		err = json.Unmarshal(output, &parentOutput)
+		if err != nil {
+			return nil, false, false, fmt.Errorf("unmarshal parent output: %w", err)
+		}
+		for k, v := range parentOutput {
+			input[k] = v
+		}
+	}
+	if !ready {
+		return nil, false, false, nil
+	}
+	return input, true, false, nil
+}
+
+// isBranchEdge returns true if a task only depends on its parent through
+// one of the parent's branches.
+func isBranchEdge(workflow *config.Workflow, parent, child string) bool {
+	if task := workflow.Tasks[child]; task != nil {
+		for _, need := range task.Needs {
+			if need == parent {
+				return false
+			}
+		}
+	}
+	if task := workflow.Tasks[parent]; task != nil {
+		for _, branch := range task.Branches {
+			if branch.Next == child {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skipTask records a task, and the tasks that depend on it, as skipped.
+func (api *API) skipTask(workflowRunID string, workflow *config.Workflow, workflowTaskID string) error {
+	taskID, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	taskType := "container"
+	if taskDef := workflow.Tasks[workflowTaskID]; taskDef != nil && taskDef.Type != "" {
+		taskType = taskDef.Type
+	}
+	result, err := api.db.Exec(`insert into tasks (id, workflow_run_id, workflow_task_id, type, created_at, completed_at, attempts_left, skipped) values
+	($1, $2, $3, $4, datetime('now'), datetime('now'), 0, 1) on conflict (workflow_run_id, workflow_task_id) do nothing`,
+		taskID.String(), workflowRunID, workflowTaskID, taskType)
+	if err != nil {
+		return err
+	}
+	// The task was already skipped through another parent.
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	for _, child := range workflow.Children(workflowTaskID) {
+		err = api.skipTask(workflowRunID, workflow, child)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FailTimedOutTasks fails the attempts of tasks that timed out. Like other
+// failures, tasks with attempts left are retried after their backoff.
+func (api *API) FailTimedOutTasks() error {
+	rows, err := api.db.Query(`select id from tasks where
+		completed_at is null and
+		started_at is not null and
+		timeout_at < datetime('now')`)
+	if err != nil {
+		return fmt.Errorf("query timed out tasks: %w", err)
+	}
+	ids := []string{}
+	for rows.Next() {
+		id := ""
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("scan task: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		err = api.CompleteTask(TaskResult{
+			ID:     id,
+			Stderr: "timed out",
+		})
+		if errors.Is(err, errStaleAttempt) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("complete task %s: %w", id, err)
+		}
+		log.Printf("task %s timed out", id)
+	}
+	return nil
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// startTaskAttempt records a new attempt of a task by a runner. Attempts
+// of the task that are still open timed out, so they're closed first.
+func startTaskAttempt(db execer, taskID, runner string) (string, error) {
+	attemptID, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	_, err = db.Exec(`update task_attempts set completed_at = datetime('now'), success = 0, stderr = 'timed out'
+	where task_id = $1 and completed_at is null`, taskID)
+	if err != nil {
+		return "", fmt.Errorf("close timed out attempts: %w", err)
+	}
+	_, err = db.Exec(`insert into task_attempts (id, task_id, runner, started_at) values ($1, $2, $3, datetime('now'))`,
+		attemptID.String(), taskID, runner)
+	if err != nil {
+		return "", fmt.Errorf("insert attempt: %w", err)
+	}
+	return attemptID.String(), nil
+}
+
+func (api *API) completeTaskAttempt(result TaskResult, marshaledOutput []byte) error {
+	query := `update task_attempts set completed_at = datetime('now'), success = $1, exit_code = $2, stdout = $3, stderr = $4, output = $5
+	where completed_at is null and `
+	args := []interface{}{result.OK, result.ExitCode, result.Stdout, result.Stderr, marshaledOutput}
+	if result.AttemptID != "" {
+		query += "id = $6"
+		args = append(args, result.AttemptID)
+	} else {
+		// Results without an attempt ID close the latest open attempt.
+		query += "id = (select id from task_attempts where task_id = $6 and completed_at is null order by started_at desc limit 1)"
+		args = append(args, result.ID)
+	}
+	res, err := api.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("update attempt: %w", err)
+	}
+	// The attempt was already closed, because it timed out and the task
+	// was claimed again.
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update attempt: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("task %s: %w", result.ID, errStaleAttempt)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+const branchWorkflowConfig = `
+workflows:
+  - id: branching
+    tasks:
+      check:
+        image: alpine
+        branches:
+          - when: large
+            next: large_orders
+          - next: small_orders
+      prepare:
+        image: alpine
+      large_orders:
+        image: alpine
+        needs: [prepare]
+      small_orders:
+        image: alpine
+      report:
+        image: alpine
+        needs: [large_orders]
+`
+
+func TestBranchTargetWithOtherParentIsSkipped(t *testing.T) {
+	api := newTestAPI(t, branchWorkflowConfig)
+	run := startTestRun(t, api, "branching", nil)
+
+	runTestTask(t, api, run, "prepare", true, nil)
+	runTestTask(t, api, run, "check", true, map[string]interface{}{"large": false})
+	expected := map[string]string{
+		"check":        "succeeded",
+		"prepare":      "succeeded",
+		"large_orders": "skipped",
+		"report":       "skipped",
+		"small_orders": "scheduled",
+	}
+	if states := testTaskStates(t, api, run); !reflect.DeepEqual(states, expected) {
+		t.Errorf("expected %v, got %v", expected, states)
+	}
+	runTestTask(t, api, run, "small_orders", true, nil)
+	if state := testRunState(t, api, run); state != "succeeded" {
+		t.Errorf("expected the run to succeed, got %s", state)
+	}
+
+	// The branch is skipped the same way when the other parent completes
+	// after it was chosen.
+	run = startTestRun(t, api, "branching", nil)
+	runTestTask(t, api, run, "check", true, map[string]interface{}{"large": false})
+	runTestTask(t, api, run, "prepare", true, nil)
+	if states := testTaskStates(t, api, run); !reflect.DeepEqual(states, expected) {
+		t.Errorf("expected %v, got %v", expected, states)
+	}
+}
+
+func TestChosenBranchTargetWaitsForOtherParent(t *testing.T) {
+	api := newTestAPI(t, branchWorkflowConfig)
+	run := startTestRun(t, api, "branching", nil)
+
+	runTestTask(t, api, run, "check", true, map[string]interface{}{"large": true})
+	expected := map[string]string{
+		"check":        "succeeded",
+		"prepare":      "scheduled",
+		"small_orders": "skipped",
+	}
+	if states := testTaskStates(t, api, run); !reflect.DeepEqual(states, expected) {
+		t.Errorf("expected %v, got %v", expected, states)
+	}
+	runTestTask(t, api, run, "prepare", true, nil)
+	runTestTask(t, api, run, "large_orders", true, nil)
+	runTestTask(t, api, run, "report", true, nil)
+	if state := testRunState(t, api, run); state != "succeeded" {
+		t.Errorf("expected the run to succeed, got %s", state)
+	}
+}
+
+const retryWorkflowConfig = `
+workflows:
+  - id: retrying
+    tasks:
+      load:
+        image: alpine
+        retries: 2
+        timeout: 10m
+        retry_backoff:
+          type: fixed
+          delay: 1h
+`
+
+func TestFailedTaskIsRetried(t *testing.T) {
+	api := newTestAPI(t, retryWorkflowConfig)
+	run := startTestRun(t, api, "retrying", nil)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		runTestTask(t, api, run, "load", false, nil)
+
+		attemptsLeft := 0
+		completed, started, delayed := false, false, false
+		err := api.db.QueryRow(`select attempts_left, completed_at is not null, started_at is not null,
+			coalesce(run_at > datetime('now', '+59 minutes'), 0)
+		from tasks where workflow_run_id = $1`, run).Scan(&attemptsLeft, &completed, &started, &delayed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attemptsLeft != 3-attempt {
+			t.Errorf("attempt %d: expected %d attempts left, got %d", attempt, 3-attempt, attemptsLeft)
+		}
+		if attempt < 3 {
+			if completed || started || !delayed {
+				t.Errorf("attempt %d: expected the task to be requeued after the backoff", attempt)
+			}
+			if state := testRunState(t, api, run); state != "running" {
+				t.Errorf("attempt %d: expected the run to be running, got %s", attempt, state)
+			}
+			continue
+		}
+		if states := testTaskStates(t, api, run); states["load"] != "failed" {
+			t.Errorf("expected the task to fail after its last attempt, got %s", states["load"])
+		}
+		if state := testRunState(t, api, run); state != "failed" {
+			t.Errorf("expected the run to fail, got %s", state)
+		}
+	}
+
+	attempts := 0
+	err := api.db.QueryRow("select count(*) from task_attempts where success = 0").Scan(&attempts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 failed attempts, got %d", attempts)
+	}
+}
+
+func TestTimedOutTasks(t *testing.T) {
+	api := newTestAPI(t, retryWorkflowConfig)
+	run := startTestRun(t, api, "retrying", nil)
+	taskID, attemptID := claimTestTask(t, api, run, "load")
+	_, err := api.db.Exec("update tasks set timeout_at = datetime('now', '-1 minute') where id = $1", taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tasks with attempts left are retried after the backoff.
+	err = api.FailTimedOutTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, delayed := false, false
+	err = api.db.QueryRow(`select started_at is not null, coalesce(run_at > datetime('now', '+59 minutes'), 0)
+	from tasks where id = $1`, taskID).Scan(&started, &delayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states := testTaskStates(t, api, run); states["load"] != "scheduled" || started || !delayed {
+		t.Errorf("expected the task to be requeued after the backoff, got %s", states["load"])
+	}
+	stderr := ""
+	err = api.db.QueryRow("select stderr from task_attempts where id = $1", attemptID).Scan(&stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr != "timed out" {
+		t.Errorf("expected the attempt to time out, got %q", stderr)
+	}
+
+	// The last attempt fails the task and the run.
+	claimTestTask(t, api, run, "load")
+	_, err = api.db.Exec("update tasks set attempts_left = 0, timeout_at = datetime('now', '-1 minute') where id = $1", taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = api.FailTimedOutTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = api.db.QueryRow("select stderr from tasks where id = $1", taskID).Scan(&stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states := testTaskStates(t, api, run); states["load"] != "failed" || stderr != "timed out" {
+		t.Errorf("expected the task to fail with a timeout, got %s: %s", states["load"], stderr)
+	}
+	if state := testRunState(t, api, run); state != "failed" {
+		t.Errorf("expected the run to fail, got %s", state)
+	}
+}
@@ -1,12 +1,25 @@
 package api
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
+
+	"github.com/crossjoin-io/crossjoin/config"
 )
 
 func (api *API) Tick(now time.Time) error {
+	err := api.FailTimedOutTasks()
+	if err != nil {
+		log.Println(fmt.Errorf("fail timed out tasks: %w", err))
+	}
+	err = api.RunServerTasks()
+	if err != nil {
+		log.Println(fmt.Errorf("run server tasks: %w", err))
+	}
+
 	datasets, err := api.ReadDatasets()
 	if err != nil {
 		return err
@@ -17,24 +30,100 @@ func (api *API) Tick(now time.Time) error {
 		return fmt.Errorf("read latest config hash: %w", err)
 	}
 
-	// refresh datasets
+	err = api.RunWorkflowSchedules(hash, now)
+	if err != nil {
+		log.Println(fmt.Errorf("run workflow schedules: %w", err))
+	}
+
+	// Datasets are refreshed after the datasets they read from, so that
+	// they're due as soon as those have been refreshed.
+	datasets, err = config.OrderDatasets(datasets)
+	if err != nil {
+		return err
+	}
 	for _, dataset := range datasets {
-		if dataset.Refresh != nil {
-			dur, err := time.ParseDuration(dataset.Refresh.Interval)
+		if dataset.Refresh != nil || len(dataset.Upstream()) > 0 {
+			due, err := api.datasetRefreshDue(dataset, now)
 			if err != nil {
-				return fmt.Errorf("parse refresh interval: %w", err)
+				return fmt.Errorf("check refresh of %s: %w", dataset.ID, err)
 			}
-
-			if lastRefresh, ok := api.lastRefresh.Load(dataset.ID); !ok || lastRefresh.(time.Time).Before(now.Add(-dur)) {
+			if due {
 				log.Println("refreshing", dataset.ID)
 				err = api.refreshDataset(hash, dataset.ID)
 				if err != nil {
-					return fmt.Errorf("refreshing %s: %w", dataset.ID, err)
+					// The failure is recorded and retried after datasetRetryDelay.
+					log.Println(fmt.Errorf("refreshing %s: %w", dataset.ID, err))
+					continue
+				}
+				_, err = api.db.Exec("REPLACE INTO dataset_schedules (dataset_id, last_refreshed_at) VALUES ($1, $2)",
+					dataset.ID, now.UTC())
+				if err != nil {
+					return fmt.Errorf("store last refresh of %s: %w", dataset.ID, err)
 				}
-				api.lastRefresh.Store(dataset.ID, now)
 			}
 		}
 	}
 
 	return nil
 }
+
+// datasetRetryDelay is how long to wait before retrying a failed refresh.
+const datasetRetryDelay = time.Minute
+
+// datasetRefreshDue returns true if the dataset hasn't been refreshed
+// yet, a dataset it reads from has been refreshed since, or its next
+// refresh time has passed. Datasets without a refresh schedule are
+// otherwise only due to retry a failed refresh. Refreshes are ordered by
+// rowid, since started_at only has a resolution of a second.
+func (api *API) datasetRefreshDue(dataset config.Dataset, now time.Time) (bool, error) {
+	upstream := dataset.Upstream()
+	if len(upstream) > 0 {
+		args := []interface{}{dataset.ID}
+		placeholders := []string{}
+		for _, id := range upstream {
+			args = append(args, id)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+		var refreshed bool
+		err := api.db.QueryRow("SELECT EXISTS (SELECT 1 FROM dataset_refreshes "+
+			"WHERE rowid > COALESCE((SELECT MAX(rowid) FROM dataset_refreshes WHERE dataset_id = $1), 0) "+
+			"AND success = 1 AND dataset_id IN ("+strings.Join(placeholders, ",")+"))", args...).
+			Scan(&refreshed)
+		if err != nil {
+			return false, err
+		}
+		if refreshed {
+			return true, nil
+		}
+	}
+
+	var lastFailure time.Time
+	err := api.db.QueryRow("SELECT started_at FROM dataset_refreshes WHERE dataset_id = $1 AND success = 0 "+
+		"AND rowid = (SELECT MAX(rowid) FROM dataset_refreshes WHERE dataset_id = $1)", dataset.ID).
+		Scan(&lastFailure)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	failed := err == nil
+	if failed && lastFailure.After(now.Add(-datasetRetryDelay)) {
+		return false, nil
+	}
+
+	var lastRefresh time.Time
+	err = api.db.QueryRow("SELECT last_refreshed_at FROM dataset_schedules WHERE dataset_id = $1", dataset.ID).
+		Scan(&lastRefresh)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if dataset.Refresh == nil {
+		return failed, nil
+	}
+	next, err := dataset.Refresh.Next(lastRefresh)
+	if err != nil {
+		return false, err
+	}
+	return !next.After(now), nil
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const upstreamDatasetConfig = `
+data_connections:
+  - id: orders
+    type: csv
+    path: ./orders.csv
+datasets:
+  - id: orders
+    refresh:
+      interval: 1h
+    data_source:
+      id: raw_orders
+      data_connection: orders
+  - id: totals
+    data_source:
+      id: order_totals
+      dataset: orders
+      query: SELECT region, SUM(amount) AS total FROM orders GROUP BY region
+`
+
+func TestDownstreamRefreshInSameSecond(t *testing.T) {
+	api := newTestAPI(t, upstreamDatasetConfig)
+	err := os.WriteFile(filepath.Join(api.dataDir, "orders.csv"), []byte("region,amount\nWest,10\nEast,5\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	err = api.Tick(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datasets, err := api.ReadDatasets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := map[string]bool{}
+	for _, dataset := range datasets {
+		due[dataset.ID], err = api.datasetRefreshDue(dataset, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if due["orders"] || due["totals"] {
+		t.Fatalf("expected both datasets to be up to date, got %v", due)
+	}
+
+	// The upstream dataset is refreshed again, most likely within the same
+	// second as the downstream refresh started.
+	hash, err := api.LatestConfigHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = api.refreshDataset(hash, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dataset := range datasets {
+		if dataset.ID != "totals" {
+			continue
+		}
+		due, err := api.datasetRefreshDue(dataset, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !due {
+			t.Error("expected the downstream dataset to be due after the upstream refresh")
+		}
+	}
+}
+
+func TestDatasetRefreshRetry(t *testing.T) {
+	api := newTestAPI(t, upstreamDatasetConfig)
+	ordersFile := filepath.Join(api.dataDir, "orders.csv")
+	err := os.WriteFile(ordersFile, []byte("region,amount\nWest,10\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	err = api.Tick(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next scheduled refresh fails.
+	err = os.Remove(ordersFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = api.db.Exec("UPDATE dataset_schedules SET last_refreshed_at = $1 WHERE dataset_id = 'orders'",
+		now.Add(-2*time.Hour).UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = api.Tick(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshes := []DatasetRefresh{}
+	status := testRequest(t, api, http.MethodGet, "/api/datasets/orders/refreshes", nil, &refreshes)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if len(refreshes) != 2 {
+		t.Fatalf("expected 2 refreshes, got %+v", refreshes)
+	}
+	failed, succeeded := refreshes[0], refreshes[1]
+	if failed.Success == nil || *failed.Success || failed.Error == nil || failed.CompletedAt == nil {
+		t.Errorf("expected the latest refresh to have failed, got %+v", failed)
+	}
+	if succeeded.Success == nil || !*succeeded.Success || succeeded.RowCounts["raw_orders"] != 1 {
+		t.Errorf("expected the first refresh to have succeeded, got %+v", succeeded)
+	}
+
+	datasets, err := api.ReadDatasets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dataset := range datasets {
+		due, err := api.datasetRefreshDue(dataset, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if due {
+			t.Errorf("expected %s not to be due before the retry delay", dataset.ID)
+		}
+		due, err = api.datasetRefreshDue(dataset, now.Add(datasetRetryDelay+time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Only the failed refresh is retried, since the downstream dataset
+		// only follows successful refreshes.
+		if expected := dataset.ID == "orders"; due != expected {
+			t.Errorf("expected %s to be due after the retry delay: %v, got %v", dataset.ID, expected, due)
+		}
+	}
+
+	// The retry succeeds, so the downstream dataset follows it.
+	err = os.WriteFile(ordersFile, []byte("region,amount\nWest,10\nEast,5\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = api.Tick(now.Add(datasetRetryDelay + time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := testQueryDataset(t, api, "totals", "SELECT region, total FROM totals ORDER BY region")
+	if len(records) != 2 {
+		t.Errorf("expected the downstream dataset to be refreshed, got %v", records)
+	}
+}
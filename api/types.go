@@ -6,38 +6,47 @@ import (
 )
 
 type Task struct {
-	ID       string                 `json:"id"`
-	Image    string                 `json:"image"`
-	Script   string                 `json:"script"`
-	Env      map[string]string      `yaml:"env"`
-	Datasets []string               `json:"datasets"`
-	Input    map[string]interface{} `yaml:"input"`
+	ID        string            `json:"id"`
+	AttemptID string            `json:"attempt_id"`
+	Image     string            `json:"image"`
+	Script    string            `json:"script"`
+	Env       map[string]string `yaml:"env"`
+	Datasets  []string          `json:"datasets"`
+	// DatasetVersions maps datasets to the versions the task should download.
+	DatasetVersions map[string]string      `json:"dataset_versions"`
+	Input           map[string]interface{} `yaml:"input"`
 }
 
 type TaskResult struct {
-	ID     string                 `json:"id"`
-	OK     bool                   `json:"ok"`
-	Output map[string]interface{} `json:"output"`
-	Stdout string                 `json:"stdout"`
-	Stderr string                 `json:"stderr"`
+	ID        string                 `json:"id"`
+	AttemptID string                 `json:"attempt_id"`
+	OK        bool                   `json:"ok"`
+	ExitCode  *int                   `json:"exit_code"`
+	Output    map[string]interface{} `json:"output"`
+	Stdout    string                 `json:"stdout"`
+	Stderr    string                 `json:"stderr"`
 }
 
 type WorkflowRun struct {
-	ID          string     `json:"id"`
-	ConfigHash  string     `json:"config_hash"`
-	WorkflowID  string     `json:"workflow_id"`
-	StartedAt   *time.Time `json:"started_at"`
-	CompletedAt *time.Time `json:"completed_at"`
-	Success     *bool      `json:"success"`
+	ID              string            `json:"id"`
+	ConfigHash      string            `json:"config_hash"`
+	WorkflowID      string            `json:"workflow_id"`
+	StartedAt       *time.Time        `json:"started_at"`
+	CompletedAt     *time.Time        `json:"completed_at"`
+	Success         *bool             `json:"success"`
+	Input           json.RawMessage   `json:"input"`
+	DatasetVersions map[string]string `json:"dataset_versions"`
 }
 
 type TaskRun struct {
 	ID             string          `json:"id"`
 	WorkflowRunID  string          `json:"workflow_run_id"`
 	WorkflowTaskID string          `json:"workflow_task_id"`
+	Type           string          `json:"type"`
 	Input          json.RawMessage `json:"input"`
 	Output         json.RawMessage `json:"output"`
 	CreatedAt      time.Time       `json:"created_at"`
+	RunAt          *time.Time      `json:"run_at"`
 	StartedAt      *time.Time      `json:"started_at"`
 	TimeoutAt      *time.Time      `json:"timeout_at"`
 	CompletedAt    *time.Time      `json:"completed_at"`
@@ -45,6 +54,20 @@ type TaskRun struct {
 	Stdout         *string         `json:"stdout"`
 	Stderr         *string         `json:"stderr"`
 	Success        *bool           `json:"success"`
+	Skipped        bool            `json:"skipped"`
+}
+
+type TaskAttempt struct {
+	ID          string          `json:"id"`
+	TaskID      string          `json:"task_id"`
+	Runner      string          `json:"runner"`
+	StartedAt   time.Time       `json:"started_at"`
+	CompletedAt *time.Time      `json:"completed_at"`
+	Success     *bool           `json:"success"`
+	ExitCode    *int            `json:"exit_code"`
+	Stdout      *string         `json:"stdout"`
+	Stderr      *string         `json:"stderr"`
+	Output      json.RawMessage `json:"output"`
 }
 
 type DataConnection struct {
@@ -54,6 +77,18 @@ type DataConnection struct {
 	ConnectionString string `json:"connection_string"`
 }
 
+type DatasetRefresh struct {
+	ID          string           `json:"id"`
+	DatasetID   string           `json:"dataset_id"`
+	ConfigHash  string           `json:"config_hash"`
+	StartedAt   time.Time        `json:"started_at"`
+	CompletedAt *time.Time       `json:"completed_at"`
+	Success     *bool            `json:"success"`
+	Error       *string          `json:"error"`
+	RowCounts   map[string]int64 `json:"row_counts"`
+	FileSize    *int64           `json:"file_size"`
+}
+
 type StatusSummary struct {
 	RecentTaskRuns      []SummaryTaskRun `json:"recent_task_runs"`
 	RecentTaskFailures  []SummaryTaskRun `json:"recent_task_failures"`
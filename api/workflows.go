@@ -16,23 +16,70 @@ func (api *API) StoreWorkflow(hash string, workflow config.Workflow) error {
 }
 
 func (api *API) StartWorkflow(hash, id string, workflowInput map[string]interface{}) error {
+	_, err := api.startWorkflowRun(hash, id, workflowInput, nil)
+	return err
+}
+
+// startWorkflowRun creates a workflow run and schedules its first tasks.
+// The run uses the given versions of datasets, or the latest versions
+// if datasetVersions is nil.
+func (api *API) startWorkflowRun(hash, id string, workflowInput map[string]interface{}, datasetVersions map[string]string) (string, error) {
+	workflow, err := api.GetWorkflow(hash, id)
+	if err != nil {
+		return "", err
+	}
+
+	if datasetVersions == nil {
+		datasetVersions, err = api.latestDatasetVersions(workflow.Datasets())
+		if err != nil {
+			return "", err
+		}
+	}
+	marshaledDatasetVersions, err := json.Marshal(datasetVersions)
+	if err != nil {
+		return "", err
+	}
+	if workflowInput == nil {
+		workflowInput = map[string]interface{}{}
+	}
+	marshaledInput, err := json.Marshal(workflowInput)
+	if err != nil {
+		return "", err
+	}
+
 	// Create a workflow run
 	workflowRunID, err := uuid.NewRandom()
 	if err != nil {
-		return err
+		return "", err
 	}
-	_, err = api.db.Exec(`INSERT INTO workflow_runs (id, config_hash, workflow_id, started_at)
-	VALUES ($1, $2, $3, datetime('now'))`, workflowRunID.String(), hash, id)
+	_, err = api.db.Exec(`INSERT INTO workflow_runs (id, config_hash, workflow_id, started_at, input, dataset_versions)
+	VALUES ($1, $2, $3, datetime('now'), $4, $5)`, workflowRunID.String(), hash, id, marshaledInput, marshaledDatasetVersions)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	workflow, err := api.GetWorkflow(hash, id)
-	if err != nil {
-		return err
+	for _, taskID := range workflow.StartTasks() {
+		err = api.ScheduleTask(workflowRunID.String(), taskID, workflowInput)
+		if err != nil {
+			return "", err
+		}
 	}
+	return workflowRunID.String(), nil
+}
 
-	return api.ScheduleTask(workflowRunID.String(), workflow.Start, workflowInput)
+// workflowRunDatasetVersions returns the dataset versions pinned by a workflow run.
+func (api *API) workflowRunDatasetVersions(workflowRunID string) (map[string]string, error) {
+	var text []byte
+	err := api.db.QueryRow("SELECT dataset_versions FROM workflow_runs WHERE id = $1", workflowRunID).Scan(&text)
+	if err != nil {
+		return nil, err
+	}
+	versions := map[string]string{}
+	err = json.Unmarshal(text, &versions)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal dataset versions: %w", err)
+	}
+	return versions, nil
 }
 
 func (api *API) CompleteWorkflowRun(id string, success bool) error {
@@ -118,7 +165,26 @@ func (api *API) ScheduleTask(workflowRunID string, workflowTaskID string, input
 	if err != nil {
 		return err
 	}
-	_, err = api.db.Exec(`insert into tasks (id, workflow_run_id, workflow_task_id, input, created_at) values
-	($1, $2, $3, $4, datetime('now'))`, taskID.String(), workflowRunID, workflowTaskID, marshaledTaskInput)
+
+	taskType := taskDef.Type
+	if taskType == "" {
+		taskType = "container"
+	}
+	// Tasks with a run_at time aren't started before then.
+	var runAt *string
+	if taskType == "delay" {
+		dur, err := config.ParseDuration(taskDef.DelayDuration())
+		if err != nil {
+			return err
+		}
+		modifier := sqliteModifier(dur)
+		runAt = &modifier
+	}
+
+	// A task with multiple parents may be scheduled by more than one of them,
+	// but it only runs once per workflow run.
+	_, err = api.db.Exec(`insert into tasks (id, workflow_run_id, workflow_task_id, type, input, created_at, run_at, attempts_left) values
+	($1, $2, $3, $4, $5, datetime('now'), datetime('now', $6), $7) on conflict (workflow_run_id, workflow_task_id) do nothing`,
+		taskID.String(), workflowRunID, workflowTaskID, taskType, marshaledTaskInput, runAt, taskDef.Attempts())
 	return err
 }
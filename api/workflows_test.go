@@ -0,0 +1,108 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorkflowFanIn(t *testing.T) {
+	api := newTestAPI(t, `
+workflows:
+  - id: fan_in
+    tasks:
+      extract_orders:
+        image: alpine
+      extract_returns:
+        image: alpine
+      join:
+        image: alpine
+        needs: [extract_orders, extract_returns]
+        with:
+          format: csv
+        next: notify
+      notify:
+        image: alpine
+`)
+	run := startTestRun(t, api, "fan_in", map[string]interface{}{"day": "2024-01-02"})
+	expected := map[string]string{
+		"extract_orders":  "scheduled",
+		"extract_returns": "scheduled",
+	}
+	if states := testTaskStates(t, api, run); !reflect.DeepEqual(states, expected) {
+		t.Errorf("expected %v, got %v", expected, states)
+	}
+
+	// The join waits for both of its parents.
+	runTestTask(t, api, run, "extract_returns", true, map[string]interface{}{"returns": 2.0, "source": "returns"})
+	if _, ok := testTaskStates(t, api, run)["join"]; ok {
+		t.Fatal("join was scheduled before all of its parents completed")
+	}
+	runTestTask(t, api, run, "extract_orders", true, map[string]interface{}{"orders": 10.0, "source": "orders"})
+
+	// Outputs are merged in order of the parents' IDs.
+	expectedInput := map[string]interface{}{
+		"format":  "csv",
+		"orders":  10.0,
+		"returns": 2.0,
+		"source":  "returns",
+	}
+	if input := testTaskInput(t, api, run, "join"); !reflect.DeepEqual(input, expectedInput) {
+		t.Errorf("expected input %v, got %v", expectedInput, input)
+	}
+	if input := testTaskInput(t, api, run, "extract_orders"); input["day"] != "2024-01-02" {
+		t.Errorf("expected the workflow input, got %v", input)
+	}
+
+	runTestTask(t, api, run, "join", true, map[string]interface{}{"rows": 8.0})
+	if state := testRunState(t, api, run); state != "running" {
+		t.Errorf("expected the run to be running, got %s", state)
+	}
+	runTestTask(t, api, run, "notify", true, nil)
+	if state := testRunState(t, api, run); state != "succeeded" {
+		t.Errorf("expected the run to succeed, got %s", state)
+	}
+}
+
+func TestWorkflowFanInFailure(t *testing.T) {
+	api := newTestAPI(t, `
+workflows:
+  - id: fan_in
+    tasks:
+      extract_orders:
+        image: alpine
+        retries: 0
+      extract_returns:
+        image: alpine
+      join:
+        image: alpine
+        needs: [extract_orders, extract_returns]
+`)
+	run := startTestRun(t, api, "fan_in", nil)
+	runTestTask(t, api, run, "extract_orders", false, nil)
+	if state := testRunState(t, api, run); state != "failed" {
+		t.Errorf("expected the run to fail, got %s", state)
+	}
+	runTestTask(t, api, run, "extract_returns", true, nil)
+	if _, ok := testTaskStates(t, api, run)["join"]; ok {
+		t.Error("join was scheduled after a parent failed")
+	}
+}
+
+func TestWorkflowStart(t *testing.T) {
+	api := newTestAPI(t, `
+workflows:
+  - id: started
+    start: extract
+    tasks:
+      extract:
+        image: alpine
+        next: load
+      load:
+        image: alpine
+`)
+	run := startTestRun(t, api, "started", nil)
+	expected := map[string]string{"extract": "scheduled"}
+	if states := testTaskStates(t, api, run); !reflect.DeepEqual(states, expected) {
+		t.Errorf("expected %v, got %v", expected, states)
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/crossjoin-io/crossjoin/config"
+	"github.com/xuri/excelize/v2"
+)
+
+// readXLSX reads the cells of a spreadsheet. The columns are named after
+// the header row, or after the column letter if its header is empty.
+// Numeric cells are read as numbers, and cells formatted as dates as
+// ISO 8601 text.
+func readXLSX(r io.Reader, dataConnection *config.DataConnection) ([]string, [][]interface{}, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	sheet := dataConnection.Sheet
+	if sheet == "" {
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, nil, fmt.Errorf("no sheets found")
+		}
+		sheet = sheets[0]
+	}
+	if index, err := f.GetSheetIndex(sheet); err != nil || index < 0 {
+		return nil, nil, fmt.Errorf("sheet `%s` not found", sheet)
+	}
+
+	props, err := f.GetWorkbookProps()
+	if err != nil {
+		return nil, nil, err
+	}
+	cells := &xlsxCells{
+		file:       f,
+		sheet:      sheet,
+		date1904:   props.Date1904 != nil && *props.Date1904,
+		dateStyles: map[int]bool{},
+	}
+
+	// A last column or row of 0 means that the cells aren't bounded.
+	firstCol, firstRow, lastCol, lastRow := 1, 1, 0, 0
+	if dataConnection.Range != "" {
+		parts := strings.Split(dataConnection.Range, ":")
+		firstCol, firstRow, err = excelize.CellNameToCoordinates(parts[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		lastCol, lastRow, err = excelize.CellNameToCoordinates(parts[1])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	headerRow := dataConnection.HeaderRow
+	if headerRow == 0 {
+		headerRow = firstRow
+	}
+
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) < headerRow {
+		return nil, nil, fmt.Errorf("header row %d is empty", headerRow)
+	}
+
+	header := rows[headerRow-1]
+	if lastCol == 0 {
+		lastCol = len(header)
+	}
+	columns := []string{}
+	for col := firstCol; col <= lastCol; col++ {
+		name := ""
+		if col <= len(header) {
+			name = strings.TrimSpace(header[col-1])
+		}
+		if name == "" {
+			name, err = excelize.ColumnNumberToName(col)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		columns = append(columns, name)
+	}
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("header row %d is empty", headerRow)
+	}
+
+	records := [][]interface{}{}
+	for rowNum := headerRow + 1; rowNum <= len(rows) && (lastRow == 0 || rowNum <= lastRow); rowNum++ {
+		row := rows[rowNum-1]
+		values := make([]interface{}, len(columns))
+		empty := true
+		for col := firstCol; col <= lastCol; col++ {
+			if col > len(row) || row[col-1] == "" {
+				continue
+			}
+			values[col-firstCol], err = cells.value(col, rowNum, row[col-1])
+			if err != nil {
+				return nil, nil, err
+			}
+			empty = false
+		}
+		// Skip blank rows in the middle of the sheet.
+		if empty {
+			continue
+		}
+		records = append(records, values)
+	}
+	return columns, records, nil
+}
+
+type xlsxCells struct {
+	file     *excelize.File
+	sheet    string
+	date1904 bool
+	// dateStyles caches whether cell styles are date formats.
+	dateStyles map[int]bool
+}
+
+func (c *xlsxCells) value(col, row int, raw string) (interface{}, error) {
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return nil, err
+	}
+	cellType, err := c.file.GetCellType(c.sheet, cell)
+	if err != nil {
+		return nil, err
+	}
+	switch cellType {
+	case excelize.CellTypeBool:
+		return raw == "1", nil
+	case excelize.CellTypeUnset, excelize.CellTypeNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return raw, nil
+		}
+		isDate, err := c.isDate(cell)
+		if err != nil {
+			return nil, err
+		}
+		if isDate {
+			t, err := excelize.ExcelDateToTime(n, c.date1904)
+			if err == nil {
+				switch {
+				case n < 1:
+					return t.Format("15:04:05"), nil
+				case n == math.Trunc(n):
+					return t.Format("2006-01-02"), nil
+				default:
+					return t.Format("2006-01-02 15:04:05"), nil
+				}
+			}
+		}
+		if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+			return int64(n), nil
+		}
+		return n, nil
+	default:
+		return raw, nil
+	}
+}
+
+func (c *xlsxCells) isDate(cell string) (bool, error) {
+	styleID, err := c.file.GetCellStyle(c.sheet, cell)
+	if err != nil {
+		return false, err
+	}
+	if isDate, ok := c.dateStyles[styleID]; ok {
+		return isDate, nil
+	}
+	style, err := c.file.GetStyle(styleID)
+	if err != nil {
+		return false, err
+	}
+	isDate := isDateFormat(style.NumFmt, style.CustomNumFmt)
+	c.dateStyles[styleID] = isDate
+	return isDate, nil
+}
+
+// Quoted text, escaped characters and sections in brackets (like colors
+// and currencies) are ignored when looking for date parts in formats.
+var numFmtLiteralRegexp = regexp.MustCompile(`"[^"]*"|\\.|\[[^\]]*\]`)
+
+func isDateFormat(numFmt int, customNumFmt *string) bool {
+	switch {
+	case numFmt >= 14 && numFmt <= 22,
+		numFmt >= 27 && numFmt <= 36,
+		numFmt >= 45 && numFmt <= 47,
+		numFmt >= 50 && numFmt <= 58:
+		return true
+	}
+	if customNumFmt == nil {
+		return false
+	}
+	format := numFmtLiteralRegexp.ReplaceAllString(*customNumFmt, "")
+	return strings.ContainsAny(format, "dDmMyYhHsS")
+}
@@ -10,8 +10,15 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
+	"github.com/expr-lang/expr"
+	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v2"
 )
 
@@ -24,12 +31,174 @@ type Config struct {
 type Dataset struct {
 	ID         string      `yaml:"id" json:"id"`
 	Refresh    *Refresh    `yaml:"refresh" json:"refresh"`
+	Retention  *Retention  `yaml:"retention,omitempty" json:"retention,omitempty"`
 	DataSource *DataSource `yaml:"data_source" json:"data_source"`
 	Joins      []Join      `yaml:"joins" json:"joins"`
+	// Select replaces the columns selected from the joined data sources,
+	// e.g. `orders."Order ID"` or `SUM(amount) AS total`.
+	Select []string `yaml:"select,omitempty" json:"select,omitempty"`
+	// Transform replaces the join with a SQL SELECT query over the tables
+	// of the data sources, which are named after their IDs. The query's
+	// syntax, tables and functions are checked when the config is loaded,
+	// but its columns aren't known until the data sources are read, so
+	// unknown columns are only reported when the dataset is refreshed.
+	Transform string `yaml:"transform,omitempty" json:"transform,omitempty"`
+}
+
+// Query returns the query of the dataset table: the transform, or the
+// join of the data sources.
+func (d *Dataset) Query() string {
+	if d.Transform != "" {
+		return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(d.Transform), ";"))
+	}
+
+	columns := "*"
+	if len(d.Select) > 0 {
+		columns = strings.Join(d.Select, ", ")
+	}
+	joinClauses := ""
+	for _, join := range d.Joins {
+		joinColumns := []string{}
+		for _, cols := range join.Columns {
+			joinColumns = append(joinColumns, fmt.Sprintf(`%s."%s" = %s."%s"`, d.DataSource.ID, cols.LeftColumn, join.DataSource.ID, cols.RightColumn))
+		}
+		joinClauses += fmt.Sprintf(" %s %s ON %s", join.Type, join.DataSource.ID, strings.Join(joinColumns, " AND "))
+	}
+	return fmt.Sprintf("SELECT %s FROM %s %s", columns, d.DataSource.ID, joinClauses)
+}
+
+// Upstream returns the IDs of the datasets that the data sources of the
+// dataset read from.
+func (d *Dataset) Upstream() []string {
+	dataSources := []*DataSource{d.DataSource}
+	for _, join := range d.Joins {
+		dataSources = append(dataSources, join.DataSource)
+	}
+	seen := map[string]bool{}
+	upstream := []string{}
+	for _, dataSource := range dataSources {
+		if dataSource != nil && dataSource.Dataset != "" && !seen[dataSource.Dataset] {
+			seen[dataSource.Dataset] = true
+			upstream = append(upstream, dataSource.Dataset)
+		}
+	}
+	return upstream
+}
+
+// OrderDatasets orders datasets so that every dataset comes after the
+// datasets it reads from, and otherwise keeps their order. It returns an
+// error if datasets read from each other in a cycle.
+func OrderDatasets(datasets []Dataset) ([]Dataset, error) {
+	byID := map[string]Dataset{}
+	for _, dataset := range datasets {
+		byID[dataset.ID] = dataset
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := map[string]int{}
+	ordered := []Dataset{}
+	var visit func(dataset Dataset) error
+	visit = func(dataset Dataset) error {
+		switch state[dataset.ID] {
+		case visiting:
+			return fmt.Errorf("dataset `%s` is part of a cycle", dataset.ID)
+		case visited:
+			return nil
+		}
+		state[dataset.ID] = visiting
+		for _, id := range dataset.Upstream() {
+			upstream, ok := byID[id]
+			if !ok {
+				return fmt.Errorf("dataset `%s` reads from unknown dataset `%s`", dataset.ID, id)
+			}
+			err := visit(upstream)
+			if err != nil {
+				return err
+			}
+		}
+		state[dataset.ID] = visited
+		ordered = append(ordered, dataset)
+		return nil
+	}
+	for _, dataset := range datasets {
+		err := visit(dataset)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Retention controls how many materialized versions of a dataset are kept.
+// The latest version is always kept.
+type Retention struct {
+	Versions int    `yaml:"versions,omitempty" json:"versions,omitempty"`
+	MaxAge   string `yaml:"max_age,omitempty" json:"max_age,omitempty"`
+}
+
+func (r *Retention) validate() error {
+	if r.Versions < 0 {
+		return errors.New("retention versions can't be negative")
+	}
+	if r.MaxAge != "" {
+		maxAge, err := ParseDuration(r.MaxAge)
+		if err != nil {
+			return fmt.Errorf("parse retention max age: %w", err)
+		}
+		if maxAge <= 0 {
+			return errors.New("retention max age must be positive")
+		}
+	}
+	return nil
 }
 
 type Refresh struct {
-	Interval string `yaml:"interval" json:"interval"`
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Cron     string `yaml:"cron,omitempty" json:"cron,omitempty"`
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+}
+
+// Next returns when a dataset last refreshed at the given time should
+// be refreshed next.
+func (r *Refresh) Next(lastRefresh time.Time) (time.Time, error) {
+	if r.Cron != "" {
+		schedule, err := ParseCron(r.Cron, r.Timezone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return schedule.Next(lastRefresh), nil
+	}
+	dur, err := ParseDuration(r.Interval)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse refresh interval: %w", err)
+	}
+	return lastRefresh.Add(dur), nil
+}
+
+func (r *Refresh) validate() error {
+	switch {
+	case r.Interval != "" && r.Cron != "":
+		return errors.New("only one of refresh interval and cron can be set")
+	case r.Interval != "":
+		dur, err := ParseDuration(r.Interval)
+		if err != nil {
+			return fmt.Errorf("parse refresh interval: %w", err)
+		}
+		if dur <= 0 {
+			return errors.New("refresh interval must be positive")
+		}
+	case r.Cron != "":
+		_, err := ParseCron(r.Cron, r.Timezone)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("missing refresh interval or cron")
+	}
+	return nil
 }
 
 type DataConnection struct {
@@ -37,12 +206,148 @@ type DataConnection struct {
 	Type             string `yaml:"type" json:"type"`
 	Path             string `yaml:"path" json:"path"`
 	ConnectionString string `yaml:"connection_string" json:"connection_string"`
+	// FlattenDepth limits how many levels of nested JSON objects are
+	// flattened into columns. Unlimited by default.
+	FlattenDepth *int `yaml:"flatten_depth,omitempty" json:"flatten_depth,omitempty"`
+	// IncludeSourceFile adds a _source_file column with the name of the
+	// file each row of a csv connection was read from.
+	IncludeSourceFile bool `yaml:"include_source_file,omitempty" json:"include_source_file,omitempty"`
+	// Sheet, HeaderRow and Range select the cells of xlsx connections.
+	// The first sheet is used by default, and the header is the first
+	// row of the range.
+	Sheet     string `yaml:"sheet,omitempty" json:"sheet,omitempty"`
+	HeaderRow int    `yaml:"header_row,omitempty" json:"header_row,omitempty"`
+	Range     string `yaml:"range,omitempty" json:"range,omitempty"`
+
+	// Fields of http connections. Environment variables in the URL,
+	// headers and auth are expanded when the request is sent.
+	URL        string            `yaml:"url,omitempty" json:"url,omitempty"`
+	Format     string            `yaml:"format,omitempty" json:"format,omitempty"` // "json" (default), "jsonl" or "csv"
+	Headers    map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Auth       *HTTPAuth         `yaml:"auth,omitempty" json:"auth,omitempty"`
+	Records    string            `yaml:"records,omitempty" json:"records,omitempty"`
+	Pagination *Pagination       `yaml:"pagination,omitempty" json:"pagination,omitempty"`
+
+	// Fields of s3 connections, which read a single object (Key) or all
+	// objects of the format under a Prefix. Credentials are taken from
+	// the standard AWS and MinIO environment variables unless set, and
+	// environment variables in them are expanded.
+	Endpoint     string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Region       string `yaml:"region,omitempty" json:"region,omitempty"`
+	Bucket       string `yaml:"bucket,omitempty" json:"bucket,omitempty"`
+	Key          string `yaml:"key,omitempty" json:"key,omitempty"`
+	Prefix       string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	AccessKey    string `yaml:"access_key,omitempty" json:"access_key,omitempty"`
+	SecretKey    string `yaml:"secret_key,omitempty" json:"secret_key,omitempty"`
+	SessionToken string `yaml:"session_token,omitempty" json:"session_token,omitempty"`
+	DisableSSL   bool   `yaml:"disable_ssl,omitempty" json:"disable_ssl,omitempty"`
+
+	// Options are the settings of data connection types that are
+	// registered by other packages.
+	Options map[string]string `yaml:"options,omitempty" json:"options,omitempty"`
+}
+
+// FileExtensions are the file extensions of file formats.
+var FileExtensions = map[string][]string{
+	"csv":     {".csv"},
+	"json":    {".json"},
+	"jsonl":   {".jsonl", ".ndjson"},
+	"parquet": {".parquet"},
+	"xlsx":    {".xlsx"},
+}
+
+// FileFormat returns the format of the objects of an s3 connection,
+// which is inferred from the extension of the key if it isn't set.
+func (dc *DataConnection) FileFormat() string {
+	if dc.Format != "" {
+		return dc.Format
+	}
+	ext := strings.ToLower(path.Ext(dc.Key))
+	for format, extensions := range FileExtensions {
+		for _, e := range extensions {
+			if ext == e {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// HTTPAuth is the authentication of http data connections.
+type HTTPAuth struct {
+	Type     string `yaml:"type" json:"type"` // "basic" or "bearer"
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+	Token    string `yaml:"token,omitempty" json:"token,omitempty"`
+}
+
+// Pagination controls how http data connections fetch more pages.
+type Pagination struct {
+	Type string `yaml:"type" json:"type"` // "cursor", "page" or "link_header"
+	// CursorPath selects the cursor of the next page in the response,
+	// which is sent as the CursorParam query parameter.
+	CursorPath  string `yaml:"cursor_path,omitempty" json:"cursor_path,omitempty"`
+	CursorParam string `yaml:"cursor_param,omitempty" json:"cursor_param,omitempty"`
+	// PageParam is the query parameter of the page number, starting at
+	// StartPage (1 by default). Pages are fetched until one is empty.
+	PageParam     string `yaml:"page_param,omitempty" json:"page_param,omitempty"`
+	StartPage     *int   `yaml:"start_page,omitempty" json:"start_page,omitempty"`
+	PageSizeParam string `yaml:"page_size_param,omitempty" json:"page_size_param,omitempty"`
+	PageSize      int    `yaml:"page_size,omitempty" json:"page_size,omitempty"`
+	// MaxPages limits the number of requests. Unlimited by default.
+	MaxPages int `yaml:"max_pages,omitempty" json:"max_pages,omitempty"`
 }
 
 type DataSource struct {
 	ID             string `yaml:"id" json:"id"`
 	DataConnection string `yaml:"data_connection" json:"data_connection"`
-	Query          string `yaml:"query" json:"query"`
+	// Dataset reads from the latest version of another dataset instead of
+	// a data connection. The query runs on the dataset's file, and reads
+	// the whole dataset table by default.
+	Dataset string `yaml:"dataset,omitempty" json:"dataset,omitempty"`
+	Query   string `yaml:"query" json:"query"`
+	// Columns overrides the types of columns, which are otherwise
+	// inferred from the data connection.
+	Columns map[string]string `yaml:"columns,omitempty" json:"columns,omitempty"`
+	// Incremental data sources only read the rows that changed since the
+	// last refresh.
+	Incremental *Incremental `yaml:"incremental,omitempty" json:"incremental,omitempty"`
+}
+
+// Incremental refreshes read the rows past the highest value of the
+// cursor column, which is passed to the query as its only parameter
+// ($1, or ? for mysql), and upsert them by the primary key into the
+// previous version of the dataset. The initial value is passed on the
+// first refresh, and whenever the data source changes. Comparing with >=
+// is safe, since rows that were already read are replaced.
+type Incremental struct {
+	CursorColumn string   `yaml:"cursor_column" json:"cursor_column"`
+	PrimaryKey   []string `yaml:"primary_key" json:"primary_key"`
+	InitialValue string   `yaml:"initial_value" json:"initial_value"`
+}
+
+func (i *Incremental) validate() error {
+	if i.CursorColumn == "" {
+		return errors.New("missing cursor column")
+	}
+	if len(i.PrimaryKey) == 0 {
+		return errors.New("missing primary key")
+	}
+	if i.InitialValue == "" {
+		return errors.New("missing initial value")
+	}
+	return nil
+}
+
+// ColumnTypes are the types of data source columns, and the SQLite types
+// they're stored as.
+var ColumnTypes = map[string]string{
+	"integer":   "INTEGER",
+	"real":      "REAL",
+	"text":      "TEXT",
+	"boolean":   "BOOLEAN",
+	"date":      "DATE",
+	"timestamp": "TIMESTAMP",
 }
 
 func (dc *DataConnection) ExpandConnectionString() {
@@ -70,7 +375,39 @@ type Workflow struct {
 }
 
 type WorkflowTrigger struct {
-	DatasetRefresh []string `yaml:"dataset_refresh" json:"dataset_refresh"`
+	DatasetRefresh []string   `yaml:"dataset_refresh" json:"dataset_refresh"`
+	Schedule       []Schedule `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+}
+
+// Schedule triggers a workflow at times matching a cron expression.
+type Schedule struct {
+	Cron     string `yaml:"cron" json:"cron"`
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+	// CatchUp controls what happens to runs missed while the server was down:
+	// "skip" (default) drops them, "once" runs once, "all" runs each of them.
+	CatchUp string `yaml:"catch_up,omitempty" json:"catch_up,omitempty"`
+}
+
+// Key uniquely identifies the schedule within a workflow.
+func (s Schedule) Key() string {
+	return s.Cron + " " + s.Timezone
+}
+
+// ParseCron parses a standard 5 field cron expression evaluated in the
+// given time zone, or UTC if it's empty.
+func ParseCron(expression, timezone string) (cron.Schedule, error) {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	_, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone `%s`", timezone)
+	}
+	schedule, err := cron.ParseStandard("CRON_TZ=" + timezone + " " + expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression `%s`: %w", expression, err)
+	}
+	return schedule, nil
 }
 
 func (w *Workflow) Parse(content []byte) error {
@@ -78,7 +415,13 @@ func (w *Workflow) Parse(content []byte) error {
 }
 
 type WorkflowTask struct {
-	Next string `yaml:"next,omitempty" json:"next,omitempty"`
+	Next     string           `yaml:"next,omitempty" json:"next,omitempty"`
+	Needs    []string         `yaml:"needs,omitempty" json:"needs,omitempty"`
+	Branches []WorkflowBranch `yaml:"branches,omitempty" json:"branches,omitempty"`
+
+	Retries      *int          `yaml:"retries,omitempty" json:"retries,omitempty"`
+	RetryBackoff *RetryBackoff `yaml:"retry_backoff,omitempty" json:"retry_backoff,omitempty"`
+	Timeout      string        `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 
 	Type         string                 `yaml:"type" json:"type"`
 	Env          map[string]string      `yaml:"env" json:"env"`
@@ -87,6 +430,375 @@ type WorkflowTask struct {
 
 	Image  string `yaml:"image,omitempty" json:"image,omitempty"` // for "container" type
 	Script string `yaml:"script,omitempty" json:"script,omitempty"`
+
+	Duration string `yaml:"duration,omitempty" json:"duration,omitempty"` // for "delay" type
+	// Params is the older form of the settings of a task. Only the
+	// duration of "delay" tasks is read from it.
+	Params map[string]string `yaml:"params,omitempty" json:"params,omitempty"`
+
+	Method         string            `yaml:"method,omitempty" json:"method,omitempty"` // for "http" type
+	URL            string            `yaml:"url,omitempty" json:"url,omitempty"`
+	Headers        map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Body           string            `yaml:"body,omitempty" json:"body,omitempty"`
+	ExpectedStatus []int             `yaml:"expected_status,omitempty" json:"expected_status,omitempty"`
+
+	// Dataset or DataConnection is queried by "sql" tasks. Datasets and
+	// sqlite data connections are read-only, so only queries that return
+	// rows can run against them.
+	Dataset        string   `yaml:"dataset,omitempty" json:"dataset,omitempty"`
+	DataConnection string   `yaml:"data_connection,omitempty" json:"data_connection,omitempty"`
+	Query          string   `yaml:"query,omitempty" json:"query,omitempty"`
+	Args           []string `yaml:"args,omitempty" json:"args,omitempty"` // input keys bound as query parameters
+}
+
+// RetryBackoff controls how long to wait before retrying a failed task.
+type RetryBackoff struct {
+	Type  string `yaml:"type" json:"type"` // "fixed" or "exponential"
+	Delay string `yaml:"delay" json:"delay"`
+	Max   string `yaml:"max,omitempty" json:"max,omitempty"`
+}
+
+const (
+	defaultTaskRetries = 2
+	defaultTaskTimeout = 5 * time.Minute
+)
+
+// Attempts returns the number of times the task is attempted before
+// the workflow run fails.
+func (t *WorkflowTask) Attempts() int {
+	if t.Retries == nil {
+		return defaultTaskRetries + 1
+	}
+	return *t.Retries + 1
+}
+
+// TimeoutDuration returns how long a single attempt of the task may run
+// before it is attempted again.
+func (t *WorkflowTask) TimeoutDuration() time.Duration {
+	if t.Timeout == "" {
+		return defaultTaskTimeout
+	}
+	dur, _ := ParseDuration(t.Timeout)
+	return dur
+}
+
+// DelayDuration returns the duration of a "delay" task, which can also
+// be set as `params.duration`.
+func (t *WorkflowTask) DelayDuration() string {
+	if t.Duration != "" {
+		return t.Duration
+	}
+	return t.Params["duration"]
+}
+
+// RetryDelay returns how long to wait before retrying the task after
+// the given number of failed attempts.
+func (t *WorkflowTask) RetryDelay(failedAttempts int) time.Duration {
+	if t.RetryBackoff == nil {
+		return 0
+	}
+	delay, _ := ParseDuration(t.RetryBackoff.Delay)
+	max := time.Duration(0)
+	if t.RetryBackoff.Max != "" {
+		max, _ = ParseDuration(t.RetryBackoff.Max)
+	}
+	if t.RetryBackoff.Type == "exponential" {
+		for i := 1; i < failedAttempts && (max == 0 || delay < max); i++ {
+			delay *= 2
+		}
+	}
+	if max > 0 && delay > max {
+		return max
+	}
+	return delay
+}
+
+// WorkflowBranch is a conditional transition to another task. The first
+// branch whose `when` expression evaluates to true over the task's output
+// is taken. A branch without `when` is always taken.
+type WorkflowBranch struct {
+	When string `yaml:"when,omitempty" json:"when,omitempty"`
+	Next string `yaml:"next,omitempty" json:"next,omitempty"`
+	End  bool   `yaml:"end,omitempty" json:"end,omitempty"` // end the workflow run successfully
+}
+
+// ChooseBranch returns the first branch that matches the output of the
+// task, or nil if none of them match.
+func (t *WorkflowTask) ChooseBranch(output map[string]interface{}) (*WorkflowBranch, error) {
+	for i, branch := range t.Branches {
+		if branch.When == "" {
+			return &t.Branches[i], nil
+		}
+		env := map[string]interface{}{}
+		for k, v := range output {
+			env[k] = v
+		}
+		program, err := expr.Compile(branch.When, expr.Env(env), expr.AllowUndefinedVariables(), expr.AsBool())
+		if err != nil {
+			return nil, fmt.Errorf("compile `%s`: %w", branch.When, err)
+		}
+		matched, err := expr.Run(program, env)
+		if err != nil {
+			return nil, fmt.Errorf("evaluate `%s`: %w", branch.When, err)
+		}
+		if matched.(bool) {
+			return &t.Branches[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// TemplateFuncs are the functions available in task templates.
+var TemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func (t *WorkflowTask) validate(datasetIDs map[string]bool, dataConnectionTypes map[string]string) error {
+	if t.Retries != nil && *t.Retries < 0 {
+		return errors.New("retries can't be negative")
+	}
+	if t.Timeout != "" {
+		dur, err := ParseDuration(t.Timeout)
+		if err != nil {
+			return fmt.Errorf("parse timeout: %w", err)
+		}
+		if dur <= 0 {
+			return errors.New("timeout must be positive")
+		}
+	}
+	if t.RetryBackoff != nil {
+		switch t.RetryBackoff.Type {
+		case "fixed", "exponential":
+		default:
+			return fmt.Errorf("unknown retry backoff type `%s`", t.RetryBackoff.Type)
+		}
+		if t.RetryBackoff.Delay == "" {
+			return errors.New("missing retry backoff delay")
+		}
+		delay, err := ParseDuration(t.RetryBackoff.Delay)
+		if err != nil {
+			return fmt.Errorf("parse retry backoff delay: %w", err)
+		}
+		if delay <= 0 {
+			return errors.New("retry backoff delay must be positive")
+		}
+		if t.RetryBackoff.Max != "" {
+			max, err := ParseDuration(t.RetryBackoff.Max)
+			if err != nil {
+				return fmt.Errorf("parse retry backoff max: %w", err)
+			}
+			if max <= 0 {
+				return errors.New("retry backoff max must be positive")
+			}
+			if max < delay {
+				return errors.New("retry backoff max can't be less than its delay")
+			}
+		}
+	}
+
+	switch t.Type {
+	case "", "container":
+		if t.Image == "" {
+			return errors.New("missing image")
+		}
+	case "delay":
+		if t.Duration != "" && t.Params["duration"] != "" {
+			return errors.New("only one of duration and params.duration can be set")
+		}
+		if t.DelayDuration() == "" {
+			return errors.New("missing duration")
+		}
+		dur, err := ParseDuration(t.DelayDuration())
+		if err != nil {
+			return err
+		}
+		if dur <= 0 {
+			return errors.New("duration must be positive")
+		}
+	case "http":
+		switch strings.ToUpper(t.Method) {
+		case "", "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS":
+		default:
+			return fmt.Errorf("unknown method `%s`", t.Method)
+		}
+		if t.URL == "" {
+			return errors.New("missing url")
+		}
+		for _, tmpl := range []string{t.URL, t.Body} {
+			_, err := template.New("").Funcs(TemplateFuncs).Parse(tmpl)
+			if err != nil {
+				return fmt.Errorf("parse template: %w", err)
+			}
+		}
+		for _, status := range t.ExpectedStatus {
+			if status < 100 || status > 599 {
+				return fmt.Errorf("invalid expected status %d", status)
+			}
+		}
+	case "sql":
+		if t.Query == "" {
+			return errors.New("missing query")
+		}
+		switch {
+		case t.Dataset != "" && t.DataConnection != "":
+			return errors.New("only one of dataset and data connection can be set")
+		case t.Dataset != "":
+			if !datasetIDs[t.Dataset] {
+				return fmt.Errorf("unknown dataset `%s`", t.Dataset)
+			}
+			if !IsSQLQuery(t.Query) {
+				return fmt.Errorf("dataset `%s` is read-only, so the query must return rows", t.Dataset)
+			}
+		case t.DataConnection != "":
+			switch dataConnectionTypes[t.DataConnection] {
+			case "":
+				return fmt.Errorf("unknown data connection `%s`", t.DataConnection)
+			case "sqlite":
+				if !IsSQLQuery(t.Query) {
+					return fmt.Errorf("sqlite data connection `%s` is read-only, so the query must return rows", t.DataConnection)
+				}
+			case "postgres", "mysql":
+			default:
+				return fmt.Errorf("data connection `%s` can't be queried", t.DataConnection)
+			}
+		default:
+			return errors.New("missing dataset or data connection")
+		}
+	default:
+		return fmt.Errorf("unknown task type `%s`", t.Type)
+	}
+	return nil
+}
+
+var sqlQueryRegexp = regexp.MustCompile(`(?i)^\s*(select|with|values|pragma|show|explain)\b`)
+
+// IsSQLQuery returns whether a statement of a "sql" task returns rows.
+// Anything else is executed and reports the number of affected rows.
+func IsSQLQuery(query string) bool {
+	return sqlQueryRegexp.MatchString(query)
+}
+
+// ParseDuration parses a duration like time.ParseDuration, but also
+// accepts a leading number of days, e.g. "1d" or "2d12h". Durations with
+// days can't be negative.
+func ParseDuration(s string) (time.Duration, error) {
+	days := 0
+	if i := strings.Index(s, "d"); i >= 0 {
+		n, err := strconv.Atoi(s[:i])
+		rest := s[i+1:]
+		if err != nil || n < 0 || strings.HasPrefix(s, "+") ||
+			strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, "+") {
+			return 0, fmt.Errorf("invalid duration `%s`", s)
+		}
+		days = n
+		s = rest
+	}
+	dur := time.Duration(0)
+	if s != "" {
+		var err error
+		dur, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return time.Duration(days)*24*time.Hour + dur, nil
+}
+
+// Parents returns the IDs of the tasks that must complete successfully
+// before the given task can start, either because the task lists them
+// in `needs` or because they point to it with `next` or a branch.
+func (w *Workflow) Parents(taskID string) []string {
+	parents := map[string]bool{}
+	if task := w.Tasks[taskID]; task != nil {
+		for _, need := range task.Needs {
+			parents[need] = true
+		}
+	}
+	for id, task := range w.Tasks {
+		if task == nil {
+			continue
+		}
+		if task.Next == taskID {
+			parents[id] = true
+		}
+		for _, branch := range task.Branches {
+			if branch.Next == taskID {
+				parents[id] = true
+			}
+		}
+	}
+	return sortedKeys(parents)
+}
+
+// Children returns the IDs of the tasks that depend on the given task.
+func (w *Workflow) Children(taskID string) []string {
+	children := map[string]bool{}
+	if task := w.Tasks[taskID]; task != nil {
+		if task.Next != "" {
+			children[task.Next] = true
+		}
+		for _, branch := range task.Branches {
+			if branch.Next != "" {
+				children[branch.Next] = true
+			}
+		}
+	}
+	for id, task := range w.Tasks {
+		if task == nil {
+			continue
+		}
+		for _, need := range task.Needs {
+			if need == taskID {
+				children[id] = true
+			}
+		}
+	}
+	return sortedKeys(children)
+}
+
+// Datasets returns the IDs of the datasets used by the workflow's tasks.
+func (w *Workflow) Datasets() []string {
+	datasets := map[string]bool{}
+	for _, task := range w.Tasks {
+		if task == nil {
+			continue
+		}
+		for _, dataset := range task.WithDatasets {
+			datasets[dataset] = true
+		}
+		if task.Dataset != "" {
+			datasets[task.Dataset] = true
+		}
+	}
+	return sortedKeys(datasets)
+}
+
+// StartTasks returns the IDs of the tasks that are scheduled as soon as a
+// workflow run starts: the start task if it's set, or else the tasks that
+// have no parents.
+func (w *Workflow) StartTasks() []string {
+	if w.Start != "" {
+		return []string{w.Start}
+	}
+	roots := map[string]bool{}
+	for id := range w.Tasks {
+		if len(w.Parents(id)) == 0 {
+			roots[id] = true
+		}
+	}
+	return sortedKeys(roots)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func (c *Config) Parse(content []byte, dir string) error {
@@ -101,7 +813,7 @@ func (c *Config) Parse(content []byte, dir string) error {
 		dataConnection.ExpandConnectionString()
 		if dataConnection.Path != "" {
 			if !path.IsAbs(dataConnection.Path) {
-				if urlDir != nil {
+				if urlDir != nil && urlDir.Scheme != "" {
 					urlPath := *urlDir
 					urlPath.Path = path.Join(urlPath.Path, dataConnection.Path)
 					c.DataConnections[i].Path = urlPath.String()
@@ -137,9 +849,11 @@ func (w Workflow) String() string {
 
 func (c *Config) validate() error {
 
+	dataConnections := map[string]*DataConnection{}
 	dataConnectionTypes := map[string]string{}
 	seenDataConnectionIDs := map[string]bool{}
-	for _, dataConnection := range c.DataConnections {
+	for i := range c.DataConnections {
+		dataConnection := &c.DataConnections[i]
 		err := dataConnection.validate()
 		if err != nil {
 			return err
@@ -148,6 +862,7 @@ func (c *Config) validate() error {
 			return fmt.Errorf("duplicate data connection ID `%s`", dataConnection.ID)
 		}
 		seenDataConnectionIDs[dataConnection.ID] = true
+		dataConnections[dataConnection.ID] = dataConnection
 		dataConnectionTypes[dataConnection.ID] = dataConnection.Type
 	}
 
@@ -161,64 +876,498 @@ func (c *Config) validate() error {
 			return fmt.Errorf("duplicate dataset ID `%s`", dataset.ID)
 		}
 		seenDataSetIDs[dataset.ID] = true
+		if dataset.Refresh != nil {
+			err := dataset.Refresh.validate()
+			if err != nil {
+				return fmt.Errorf("dataset `%s`: %w", dataset.ID, err)
+			}
+		}
+		if dataset.Retention != nil {
+			err := dataset.Retention.validate()
+			if err != nil {
+				return fmt.Errorf("dataset `%s`: %w", dataset.ID, err)
+			}
+		}
 		if dataset.DataSource == nil {
 			return errors.New("missing data source")
 		}
-		err := dataset.DataSource.validate(dataConnectionTypes[dataset.DataSource.DataConnection])
+		err := dataset.DataSource.validate(dataConnections[dataset.DataSource.DataConnection])
 		if err != nil {
 			return err
 		}
 		if dataset.ID == dataset.DataSource.ID {
 			return fmt.Errorf("data source can't have the same ID as the dataset (`%s`)", dataset.ID)
 		}
+		if dataset.Transform != "" && len(dataset.Select) > 0 {
+			return fmt.Errorf("dataset `%s` can't have both a transform and a select", dataset.ID)
+		}
 		seenDataSourceIDs[dataset.DataSource.ID] = true
 		for _, j := range dataset.Joins {
 			if j.DataSource == nil {
 				return errors.New("missing data source for join")
 			}
-			err := j.DataSource.validate(dataConnectionTypes[j.DataSource.DataConnection])
+			err := j.DataSource.validate(dataConnections[j.DataSource.DataConnection])
 			if err != nil {
 				return err
 			}
 			if seenDataSourceIDs[j.DataSource.ID] {
 				return fmt.Errorf("duplicate data source ID `%s`", j.DataSource.ID)
 			}
-			seenDataSetIDs[j.DataSource.ID] = true
+			seenDataSourceIDs[j.DataSource.ID] = true
+		}
+		for _, column := range dataset.Select {
+			if strings.TrimSpace(column) == "" {
+				return fmt.Errorf("dataset `%s` has an empty column in its select", dataset.ID)
+			}
+		}
+	}
+
+	_, err := OrderDatasets(c.Datasets)
+	if err != nil {
+		return err
+	}
+
+	seenWorkflowIDs := map[string]bool{}
+	for _, workflow := range c.Workflows {
+		if seenWorkflowIDs[workflow.ID] {
+			return fmt.Errorf("duplicate workflow ID `%s`", workflow.ID)
+		}
+		seenWorkflowIDs[workflow.ID] = true
+		err := workflow.validate(seenDataSetIDs, dataConnectionTypes)
+		if err != nil {
+			return fmt.Errorf("workflow `%s`: %w", workflow.ID, err)
 		}
 	}
 	return nil
 }
 
+func (w *Workflow) validate(datasetIDs map[string]bool, dataConnectionTypes map[string]string) error {
+	if !validID(w.ID) {
+		return fmt.Errorf("invalid ID `%s`", w.ID)
+	}
+	if w.On != nil {
+		for _, schedule := range w.On.Schedule {
+			_, err := ParseCron(schedule.Cron, schedule.Timezone)
+			if err != nil {
+				return err
+			}
+			switch schedule.CatchUp {
+			case "", "skip", "once", "all":
+			default:
+				return fmt.Errorf("unknown catch up policy `%s`", schedule.CatchUp)
+			}
+		}
+	}
+	if len(w.Tasks) == 0 {
+		return errors.New("missing tasks")
+	}
+	for id, task := range w.Tasks {
+		if task == nil {
+			return fmt.Errorf("empty task `%s`", id)
+		}
+		err := task.validate(datasetIDs, dataConnectionTypes)
+		if err != nil {
+			return fmt.Errorf("task `%s`: %w", id, err)
+		}
+		if task.Next != "" && w.Tasks[task.Next] == nil {
+			return fmt.Errorf("task `%s` has unknown next task `%s`", id, task.Next)
+		}
+		for _, need := range task.Needs {
+			if w.Tasks[need] == nil {
+				return fmt.Errorf("task `%s` needs unknown task `%s`", id, need)
+			}
+		}
+		if task.Next != "" && len(task.Branches) > 0 {
+			return fmt.Errorf("task `%s` can't have both next and branches", id)
+		}
+		for _, branch := range task.Branches {
+			switch {
+			case branch.End && branch.Next != "":
+				return fmt.Errorf("task `%s` has a branch with both next and end", id)
+			case !branch.End && branch.Next == "":
+				return fmt.Errorf("task `%s` has a branch without next or end", id)
+			case branch.Next != "" && w.Tasks[branch.Next] == nil:
+				return fmt.Errorf("task `%s` has a branch to unknown task `%s`", id, branch.Next)
+			}
+			if branch.When != "" {
+				_, err := expr.Compile(branch.When)
+				if err != nil {
+					return fmt.Errorf("task `%s` has an invalid branch condition: %w", id, err)
+				}
+			}
+		}
+	}
+	if w.Start != "" {
+		if w.Tasks[w.Start] == nil {
+			return fmt.Errorf("unknown start task `%s`", w.Start)
+		}
+		if len(w.Parents(w.Start)) > 0 {
+			return fmt.Errorf("start task `%s` can't depend on other tasks", w.Start)
+		}
+		// Only the start task is scheduled when a run starts, so other
+		// tasks would never run.
+		reachable := map[string]bool{}
+		queue := []string{w.Start}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			if reachable[id] {
+				continue
+			}
+			reachable[id] = true
+			queue = append(queue, w.Children(id)...)
+		}
+		for _, id := range sortedTaskIDs(w.Tasks) {
+			if !reachable[id] {
+				return fmt.Errorf("task `%s` can't be reached from start task `%s`", id, w.Start)
+			}
+		}
+	}
+
+	// Make sure the tasks form a DAG.
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := map[string]int{}
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("task `%s` is part of a cycle", id)
+		case visited:
+			return nil
+		}
+		state[id] = visiting
+		for _, child := range w.Children(id) {
+			err := visit(child)
+			if err != nil {
+				return err
+			}
+		}
+		state[id] = visited
+		return nil
+	}
+	for _, id := range sortedTaskIDs(w.Tasks) {
+		err := visit(id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedTaskIDs(tasks map[string]*WorkflowTask) []string {
+	ids := make([]string, 0, len(tasks))
+	for id := range tasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// DataConnectionValidator checks the config of the data connections of
+// a type and of the data sources that read from them.
+type DataConnectionValidator interface {
+	ValidateConnection(dc *DataConnection) error
+	ValidateSource(dc *DataConnection, ds *DataSource) error
+}
+
+var (
+	dataConnectionValidatorsMu sync.RWMutex
+	dataConnectionValidators   = map[string]DataConnectionValidator{
+		"postgres": builtinDataConnectionType{(*DataConnection).validateConnectionString, true},
+		"mysql":    builtinDataConnectionType{(*DataConnection).validateConnectionString, true},
+		"sqlite":   builtinDataConnectionType{(*DataConnection).validatePath, true},
+		"csv":      builtinDataConnectionType{(*DataConnection).validatePath, false},
+		"parquet":  builtinDataConnectionType{(*DataConnection).validatePath, false},
+		"json":     builtinDataConnectionType{(*DataConnection).validateJSON, false},
+		"jsonl":    builtinDataConnectionType{(*DataConnection).validateJSON, false},
+		"xlsx":     builtinDataConnectionType{(*DataConnection).validateXLSX, false},
+		"s3":       builtinDataConnectionType{(*DataConnection).validateS3, false},
+		"http": builtinDataConnectionType{func(dc *DataConnection) error {
+			err := dc.validateHTTP()
+			if err != nil {
+				return fmt.Errorf("data connection `%s`: %w", dc.ID, err)
+			}
+			return nil
+		}, false},
+	}
+)
+
+// RegisterDataConnectionType registers the validator of a data connection
+// type. It's called through connector.Register, which only registers each
+// type once. The built-in types are registered by the api package with
+// their own validators, so that configs can be validated without it.
+func RegisterDataConnectionType(name string, validator DataConnectionValidator) {
+	dataConnectionValidatorsMu.Lock()
+	defer dataConnectionValidatorsMu.Unlock()
+	dataConnectionValidators[name] = validator
+}
+
+// LookupDataConnectionType returns the validator of a data connection type.
+func LookupDataConnectionType(name string) (DataConnectionValidator, bool) {
+	dataConnectionValidatorsMu.RLock()
+	defer dataConnectionValidatorsMu.RUnlock()
+	validator, ok := dataConnectionValidators[name]
+	return validator, ok
+}
+
+type builtinDataConnectionType struct {
+	validate func(dc *DataConnection) error
+	// needsQuery is set for types whose data sources are queries.
+	needsQuery bool
+}
+
+func (t builtinDataConnectionType) ValidateConnection(dc *DataConnection) error {
+	return t.validate(dc)
+}
+
+func (t builtinDataConnectionType) ValidateSource(dc *DataConnection, ds *DataSource) error {
+	if t.needsQuery && ds.Query == "" {
+		return fmt.Errorf("missing query")
+	}
+	if !t.needsQuery && ds.Incremental != nil {
+		return fmt.Errorf("data source `%s` can't be incremental, since it isn't a query", ds.ID)
+	}
+	return nil
+}
+
 func (dc *DataConnection) validate() error {
 	if !validID(dc.ID) {
 		return fmt.Errorf("invalid ID `%s`", dc.ID)
 	}
-	switch dc.Type {
-	case "postgres":
-		if dc.ConnectionString == "" {
-			return fmt.Errorf("missing connection string for data connection `%s`", dc.ID)
+	validator, ok := LookupDataConnectionType(dc.Type)
+	if !ok {
+		return fmt.Errorf("unknown data connection type `%s`", dc.Type)
+	}
+	err := validator.ValidateConnection(dc)
+	if err != nil {
+		return err
+	}
+	if dc.IncludeSourceFile && dc.Type != "csv" && !(dc.Type == "s3" && dc.FileFormat() == "csv") {
+		return fmt.Errorf("include_source_file is only supported by csv data connections")
+	}
+	return nil
+}
+
+func (dc *DataConnection) validateConnectionString() error {
+	if dc.ConnectionString == "" {
+		return fmt.Errorf("missing connection string for data connection `%s`", dc.ID)
+	}
+	return nil
+}
+
+func (dc *DataConnection) validatePath() error {
+	if dc.Path == "" {
+		return fmt.Errorf("missing path for data connection `%s`", dc.ID)
+	}
+	return nil
+}
+
+func (dc *DataConnection) validateJSON() error {
+	if dc.Path == "" {
+		return fmt.Errorf("missing path for data connection `%s`", dc.ID)
+	}
+	if dc.FlattenDepth != nil && *dc.FlattenDepth < 0 {
+		return fmt.Errorf("invalid flatten depth for data connection `%s`", dc.ID)
+	}
+	return nil
+}
+
+func (dc *DataConnection) validateXLSX() error {
+	if dc.Path == "" {
+		return fmt.Errorf("missing path for data connection `%s`", dc.ID)
+	}
+	if dc.HeaderRow < 0 {
+		return fmt.Errorf("invalid header row for data connection `%s`", dc.ID)
+	}
+	if dc.Range != "" {
+		firstRow, lastRow, err := dc.RangeRows()
+		if err != nil {
+			return fmt.Errorf("data connection `%s`: %w", dc.ID, err)
+		}
+		if dc.HeaderRow != 0 && (dc.HeaderRow < firstRow || dc.HeaderRow > lastRow) {
+			return fmt.Errorf("header row of data connection `%s` is outside of the range", dc.ID)
+		}
+	}
+	return nil
+}
+
+func (dc *DataConnection) validateS3() error {
+	if dc.Bucket == "" {
+		return fmt.Errorf("missing bucket for data connection `%s`", dc.ID)
+	}
+	if (dc.Key == "") == (dc.Prefix == "") {
+		return fmt.Errorf("data connection `%s` needs either a key or a prefix", dc.ID)
+	}
+	format := dc.FileFormat()
+	if format == "" {
+		return fmt.Errorf("missing format for data connection `%s`", dc.ID)
+	}
+	if _, ok := FileExtensions[format]; !ok {
+		return fmt.Errorf("unknown format `%s` for data connection `%s`", format, dc.ID)
+	}
+	if format == "xlsx" && dc.Prefix != "" {
+		return fmt.Errorf("xlsx data connection `%s` needs a key", dc.ID)
+	}
+	if dc.FlattenDepth != nil && *dc.FlattenDepth < 0 {
+		return fmt.Errorf("invalid flatten depth for data connection `%s`", dc.ID)
+	}
+	return nil
+}
+
+func (dc *DataConnection) validateHTTP() error {
+	if dc.URL == "" {
+		return fmt.Errorf("missing url")
+	}
+	// URLs with environment variables can only be checked once they're
+	// expanded.
+	if !strings.Contains(dc.URL, "$") {
+		u, err := url.Parse(dc.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid url `%s`", dc.URL)
+		}
+	}
+	switch dc.Format {
+	case "", "json", "jsonl":
+		if dc.FlattenDepth != nil && *dc.FlattenDepth < 0 {
+			return fmt.Errorf("invalid flatten depth")
 		}
 	case "csv":
-		if dc.Path == "" {
-			return fmt.Errorf("missing path for data connection `%s`", dc.ID)
+		if dc.Records != "" {
+			return fmt.Errorf("records can only be selected from json")
 		}
 	default:
-		return fmt.Errorf("unknown data connection type `%s`", dc.Type)
+		return fmt.Errorf("unknown format `%s`", dc.Format)
+	}
+	if dc.Records != "" {
+		if _, err := ParseJSONPath(dc.Records); err != nil {
+			return err
+		}
+	}
+	if dc.Auth != nil {
+		switch dc.Auth.Type {
+		case "basic":
+			if dc.Auth.Username == "" {
+				return fmt.Errorf("missing username for basic auth")
+			}
+		case "bearer":
+			if dc.Auth.Token == "" {
+				return fmt.Errorf("missing token for bearer auth")
+			}
+		default:
+			return fmt.Errorf("unknown auth type `%s`", dc.Auth.Type)
+		}
+	}
+	if p := dc.Pagination; p != nil {
+		switch p.Type {
+		case "cursor":
+			if p.CursorPath == "" || p.CursorParam == "" {
+				return fmt.Errorf("cursor pagination needs a cursor_path and cursor_param")
+			}
+			if dc.Format == "csv" || dc.Format == "jsonl" {
+				return fmt.Errorf("cursor pagination needs json responses")
+			}
+			if _, err := ParseJSONPath(p.CursorPath); err != nil {
+				return err
+			}
+		case "page":
+			if p.PageParam == "" {
+				return fmt.Errorf("page pagination needs a page_param")
+			}
+			if p.PageSize < 0 {
+				return fmt.Errorf("invalid page size")
+			}
+		case "link_header":
+		default:
+			return fmt.Errorf("unknown pagination type `%s`", p.Type)
+		}
+		if p.MaxPages < 0 {
+			return fmt.Errorf("invalid max pages")
+		}
 	}
 	return nil
 }
 
-func (ds *DataSource) validate(dataConnectionType string) error {
+var jsonPathSegmentRegexp = regexp.MustCompile(`^([^.\[\]]*)((?:\[[0-9]+\])*)$`)
+
+// ParseJSONPath parses a JSONPath-style selector like $.data.items or
+// results[0].rows into keys (strings) and array indexes (ints).
+func ParseJSONPath(path string) ([]interface{}, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	segments := []interface{}{}
+	if path == "" {
+		return segments, nil
+	}
+	for _, part := range strings.Split(path, ".") {
+		match := jsonPathSegmentRegexp.FindStringSubmatch(part)
+		if match == nil || (match[1] == "" && match[2] == "") {
+			return nil, fmt.Errorf("invalid json path `%s`", path)
+		}
+		if match[1] != "" {
+			segments = append(segments, match[1])
+		}
+		for _, index := range strings.Split(match[2], "]") {
+			if index == "" {
+				continue
+			}
+			i, _ := strconv.Atoi(strings.TrimPrefix(index, "["))
+			segments = append(segments, i)
+		}
+	}
+	return segments, nil
+}
+
+var cellRangeRegexp = regexp.MustCompile(`^[A-Za-z]{1,3}([0-9]+):[A-Za-z]{1,3}([0-9]+)$`)
+
+// RangeRows returns the first and last row of the range of a spreadsheet
+// connection, e.g. 2 and 50 for A2:F50.
+func (dc *DataConnection) RangeRows() (int, int, error) {
+	match := cellRangeRegexp.FindStringSubmatch(dc.Range)
+	if match == nil {
+		return 0, 0, fmt.Errorf("invalid range `%s`", dc.Range)
+	}
+	first, _ := strconv.Atoi(match[1])
+	last, _ := strconv.Atoi(match[2])
+	if first < 1 || last < first {
+		return 0, 0, fmt.Errorf("invalid range `%s`", dc.Range)
+	}
+	return first, last, nil
+}
+
+func (ds *DataSource) validate(dataConnection *DataConnection) error {
 	if !validID(ds.ID) {
 		return fmt.Errorf("invalid ID `%s`", ds.ID)
 	}
-	switch dataConnectionType {
-	case "postgres":
-		if ds.Query == "" {
-			return fmt.Errorf("missing query")
+	for column, typ := range ds.Columns {
+		if _, ok := ColumnTypes[typ]; !ok {
+			return fmt.Errorf("unknown type `%s` of column `%s` of data source `%s`", typ, column, ds.ID)
 		}
 	}
-	return nil
+	if ds.Incremental != nil {
+		err := ds.Incremental.validate()
+		if err != nil {
+			return fmt.Errorf("data source `%s`: %w", ds.ID, err)
+		}
+	}
+	if ds.Dataset != "" {
+		if ds.DataConnection != "" {
+			return fmt.Errorf("data source `%s` can't have both a data connection and a dataset", ds.ID)
+		}
+		if ds.Incremental != nil {
+			return fmt.Errorf("data source `%s` can't be incremental, since it reads a dataset", ds.ID)
+		}
+		return nil
+	}
+	if dataConnection == nil {
+		return nil
+	}
+	validator, ok := LookupDataConnectionType(dataConnection.Type)
+	if !ok {
+		return nil
+	}
+	return validator.ValidateSource(dataConnection, ds)
 }
 
 var validIDRegexp = regexp.MustCompile(`^[a-zA-Z]([\w-]*[a-zA-Z0-9])?$`)
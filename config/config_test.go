@@ -1,6 +1,11 @@
 package config
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestParseWithWorkflow(t *testing.T) {
 	conf := &Config{}
@@ -28,3 +33,939 @@ workflows:
 	}
 	t.Log(conf)
 }
+
+func TestParseWithUnknownTaskType(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    tasks:
+      wait:
+        type: sleep
+        duration: 1d`), "")
+	if err == nil {
+		t.Fatal("expected an error for an unknown task type")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"1d":    24 * time.Hour,
+		"2d12h": 60 * time.Hour,
+		"90m":   90 * time.Minute,
+	} {
+		dur, err := ParseDuration(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dur != expected {
+			t.Errorf("expected %s for `%s`, got %s", expected, s, dur)
+		}
+	}
+	for _, s := range []string{"1x", "-1d", "1d-2h", "+1d", "1d+2h"} {
+		if _, err := ParseDuration(s); err == nil {
+			t.Errorf("expected an error for `%s`", s)
+		}
+	}
+}
+
+func TestParseWorkflowStart(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    start: extract_orders
+    tasks:
+      extract_orders:
+        image: alpine
+        next: notify
+      notify:
+        image: alpine`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(conf.Workflows[0].StartTasks(), ","); got != "extract_orders" {
+		t.Errorf("unexpected start tasks %s", got)
+	}
+
+	err = conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    start: extract_orders
+    tasks:
+      extract_orders:
+        image: alpine
+        next: notify
+      notify:
+        image: alpine
+      cleanup:
+        image: alpine`), "")
+	if err == nil {
+		t.Fatal("expected an error for a task that can't be reached from the start task")
+	}
+}
+
+func TestParseWorkflowDAG(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    tasks:
+      extract_orders:
+        image: alpine
+      extract_returns:
+        image: alpine
+      join:
+        image: alpine
+        needs: [extract_orders, extract_returns]
+        next: notify
+      notify:
+        image: alpine`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflow := conf.Workflows[0]
+	if got := strings.Join(workflow.StartTasks(), ","); got != "extract_orders,extract_returns" {
+		t.Errorf("unexpected start tasks %s", got)
+	}
+	if got := strings.Join(workflow.Parents("join"), ","); got != "extract_orders,extract_returns" {
+		t.Errorf("unexpected parents %s", got)
+	}
+	if got := strings.Join(workflow.Parents("notify"), ","); got != "join" {
+		t.Errorf("unexpected parents %s", got)
+	}
+	if got := strings.Join(workflow.Children("extract_orders"), ","); got != "join" {
+		t.Errorf("unexpected children %s", got)
+	}
+}
+
+func TestParseWorkflowCycle(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    tasks:
+      a:
+        image: alpine
+        needs: [c]
+      b:
+        image: alpine
+        needs: [a]
+      c:
+        image: alpine
+        needs: [b]`), "")
+	if err == nil {
+		t.Fatal("expected an error for a cyclic workflow")
+	}
+}
+
+func TestParseHTTPTask(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    tasks:
+      notify:
+        type: http
+        method: POST
+        url: https://hooks.example.com/{{ .channel }}
+        body: '{"text": {{ json .text }}}'
+        expected_status: [200, 202]`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    tasks:
+      notify:
+        type: http
+        url: https://hooks.example.com
+        expected_status: [2000]`), "")
+	if err == nil {
+		t.Fatal("expected an error for an invalid status")
+	}
+}
+
+func TestParseSQLTask(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+data_connections:
+  - id: orders
+    type: csv
+    path: ./orders.csv
+datasets:
+  - id: all_orders
+    data_source:
+      id: orders
+      data_connection: orders
+workflows:
+  - id: my-workflow
+    tasks:
+      count_orders:
+        type: sql
+        dataset: all_orders
+        query: SELECT COUNT(*) AS n FROM all_orders WHERE "Region" = $1
+        args: [region]`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    tasks:
+      count_orders:
+        type: sql
+        dataset: missing
+        query: SELECT 1`), "")
+	if err == nil {
+		t.Fatal("expected an error for an unknown dataset")
+	}
+
+	err = conf.Parse([]byte(`
+data_connections:
+  - id: orders
+    type: csv
+    path: ./orders.csv
+datasets:
+  - id: all_orders
+    data_source:
+      id: orders
+      data_connection: orders
+workflows:
+  - id: my-workflow
+    tasks:
+      clear_orders:
+        type: sql
+        dataset: all_orders
+        query: DELETE FROM all_orders`), "")
+	if err == nil {
+		t.Fatal("expected an error for a statement against a read-only dataset")
+	}
+}
+
+func TestParseWorkflowBranches(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    tasks:
+      count_orders:
+        image: alpine
+        branches:
+          - when: rows == 0
+            end: true
+          - next: notify
+      notify:
+        image: alpine`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := conf.Workflows[0].Tasks["count_orders"]
+	branch, err := task.ChooseBranch(map[string]interface{}{"rows": 0.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch == nil || !branch.End {
+		t.Errorf("expected the end branch, got %v", branch)
+	}
+	branch, err = task.ChooseBranch(map[string]interface{}{"rows": 3.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch == nil || branch.Next != "notify" {
+		t.Errorf("expected the notify branch, got %v", branch)
+	}
+
+	err = conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    tasks:
+      count_orders:
+        image: alpine
+        branches:
+          - when: rows > 0
+            next: missing`), "")
+	if err == nil {
+		t.Fatal("expected an error for a branch to an unknown task")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    tasks:
+      load:
+        image: alpine
+        retries: 5
+        timeout: 2h
+        retry_backoff:
+          type: exponential
+          delay: 30s
+          max: 5m`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := conf.Workflows[0].Tasks["load"]
+	if task.Attempts() != 6 {
+		t.Errorf("expected 6 attempts, got %d", task.Attempts())
+	}
+	if task.TimeoutDuration() != 2*time.Hour {
+		t.Errorf("expected a 2h timeout, got %s", task.TimeoutDuration())
+	}
+	for failedAttempts, expected := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		5:  5 * time.Minute,
+		60: 5 * time.Minute,
+	} {
+		if delay := task.RetryDelay(failedAttempts); delay != expected {
+			t.Errorf("expected %s after %d failed attempts, got %s", expected, failedAttempts, delay)
+		}
+	}
+}
+
+func TestParseWorkflowSchedule(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    on:
+      schedule:
+        - cron: "0 7 * * 1-5"
+          timezone: America/New_York
+          catch_up: once
+    tasks:
+      notify:
+        image: alpine`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule := conf.Workflows[0].On.Schedule[0]
+	cronSchedule, err := ParseCron(schedule.Cron, schedule.Timezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Saturday, so the next run is on Monday.
+	next := cronSchedule.Next(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	if expected := time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, next)
+	}
+
+	for _, schedule := range []string{
+		`cron: "0 7 * *"`,
+		`{cron: "0 7 * * *", timezone: Mars/Olympus_Mons}`,
+		`{cron: "0 7 * * *", catch_up: sometimes}`,
+	} {
+		err = conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    on:
+      schedule:
+        - `+schedule+`
+    tasks:
+      notify:
+        image: alpine`), "")
+		if err == nil {
+			t.Errorf("expected an error for schedule %s", schedule)
+		}
+	}
+}
+
+func TestRefreshNext(t *testing.T) {
+	last := time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		refresh  Refresh
+		expected time.Time
+	}{
+		{Refresh{Interval: "30m"}, last.Add(30 * time.Minute)},
+		{Refresh{Interval: "1d"}, last.Add(24 * time.Hour)},
+		{Refresh{Cron: "0 2 * * *"}, time.Date(2026, 10, 2, 2, 0, 0, 0, time.UTC)},
+		{Refresh{Cron: "0 2 * * *", Timezone: "Europe/Berlin"}, time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)},
+	} {
+		next, err := tc.refresh.Next(last)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !next.Equal(tc.expected) {
+			t.Errorf("%+v: expected %s, got %s", tc.refresh, tc.expected, next)
+		}
+	}
+}
+
+func TestParseDatasetRetention(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+data_connections:
+  - id: orders
+    type: csv
+    path: ./orders.csv
+datasets:
+  - id: all_orders
+    retention:
+      versions: 7
+      max_age: 7d
+    data_source:
+      id: orders
+      data_connection: orders`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conf.Parse([]byte(`
+data_connections:
+  - id: orders
+    type: csv
+    path: ./orders.csv
+datasets:
+  - id: all_orders
+    retention:
+      max_age: a week
+    data_source:
+      id: orders
+      data_connection: orders`), "")
+	if err == nil {
+		t.Fatal("expected an error for an invalid max age")
+	}
+}
+
+func TestParseNonPositiveDurations(t *testing.T) {
+	for _, task := range []string{
+		"type: delay\n        duration: -1h",
+		"type: delay\n        params:\n          duration: 0s",
+		"image: alpine\n        retry_backoff:\n          type: fixed\n          delay: -30s",
+		"image: alpine\n        retry_backoff:\n          type: exponential\n          delay: 30s\n          max: -5m",
+		"image: alpine\n        retry_backoff:\n          type: fixed\n          delay: 10m\n          max: 5m",
+	} {
+		conf := &Config{}
+		err := conf.Parse([]byte(`
+workflows:
+  - id: my-workflow
+    tasks:
+      task:
+        `+task), "")
+		if err == nil {
+			t.Errorf("expected an error for %q", task)
+		}
+	}
+
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+data_connections:
+  - id: orders
+    type: csv
+    path: ./orders.csv
+datasets:
+  - id: all_orders
+    retention:
+      max_age: -1h
+    data_source:
+      id: orders
+      data_connection: orders`), "")
+	if err == nil {
+		t.Error("expected an error for a negative max age")
+	}
+}
+
+func TestParseMySQLDataConnection(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+data_connections:
+  - id: shop
+    type: mysql
+    connection_string: user:pass@tcp(localhost:3306)/shop
+datasets:
+  - id: all_orders
+    data_source:
+      id: orders
+      data_connection: shop
+      query: SELECT * FROM orders`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conf.Parse([]byte(`
+data_connections:
+  - id: shop
+    type: mysql
+    connection_string: user:pass@tcp(localhost:3306)/shop
+datasets:
+  - id: all_orders
+    data_source:
+      id: orders
+      data_connection: shop`), "")
+	if err == nil {
+		t.Fatal("expected an error for a missing query")
+	}
+}
+
+func TestParseSQLiteDataConnection(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+data_connections:
+  - id: tool
+    type: sqlite
+    path: ./tool.db
+datasets:
+  - id: all_orders
+    data_source:
+      id: orders
+      data_connection: tool
+      query: SELECT * FROM orders`), "/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.DataConnections[0].Path != "/data/tool.db" {
+		t.Errorf("expected the path to be resolved, got %s", conf.DataConnections[0].Path)
+	}
+
+	err = conf.Parse([]byte(`
+data_connections:
+  - id: tool
+    type: sqlite
+datasets:
+  - id: all_orders
+    data_source:
+      id: orders
+      data_connection: tool
+      query: SELECT * FROM orders`), "")
+	if err == nil {
+		t.Fatal("expected an error for a missing path")
+	}
+}
+
+func TestParseJSONDataConnection(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+data_connections:
+  - id: events
+    type: jsonl
+    path: ./events.jsonl
+    flatten_depth: 1
+datasets:
+  - id: all_events
+    data_source:
+      id: events
+      data_connection: events`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.DataConnections[0].FlattenDepth == nil || *conf.DataConnections[0].FlattenDepth != 1 {
+		t.Errorf("expected a flatten depth of 1, got %v", conf.DataConnections[0].FlattenDepth)
+	}
+
+	err = conf.Parse([]byte(`
+data_connections:
+  - id: events
+    type: json
+    path: ./events.json
+    flatten_depth: -1
+datasets:
+  - id: all_events
+    data_source:
+      id: events
+      data_connection: events`), "")
+	if err == nil {
+		t.Fatal("expected an error for a negative flatten depth")
+	}
+}
+
+func TestParseParquetDataConnection(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+data_connections:
+  - id: lake
+    type: parquet
+datasets:
+  - id: events
+    data_source:
+      id: lake
+      data_connection: lake`), "")
+	if err == nil {
+		t.Fatal("expected an error for a missing path")
+	}
+}
+
+func TestParseXLSXDataConnection(t *testing.T) {
+	for _, tc := range []struct {
+		fields string
+		valid  bool
+	}{
+		{"sheet: Q3", true},
+		{"header_row: 3", true},
+		{"range: A3:F50", true},
+		{"range: A3:F50\n    header_row: 4", true},
+		{"range: A3", false},
+		{"range: A50:F3", false},
+		{"range: A3:F50\n    header_row: 2", false},
+		{"header_row: -1", false},
+	} {
+		conf := &Config{}
+		err := conf.Parse([]byte(`
+data_connections:
+  - id: report
+    type: xlsx
+    path: ./report.xlsx
+    `+tc.fields+`
+datasets:
+  - id: all_reports
+    data_source:
+      id: report
+      data_connection: report`), "")
+		if tc.valid && err != nil {
+			t.Errorf("%q: %s", tc.fields, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%q: expected an error", tc.fields)
+		}
+	}
+}
+
+func TestParseCSVGlob(t *testing.T) {
+	conf := &Config{}
+	err := conf.Parse([]byte(`
+data_connections:
+  - id: orders
+    type: csv
+    path: ./exports/orders_*.csv
+    include_source_file: true
+datasets:
+  - id: all_orders
+    data_source:
+      id: orders
+      data_connection: orders`), "/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.DataConnections[0].Path != "/data/exports/orders_*.csv" {
+		t.Errorf("expected the glob to be kept, got %s", conf.DataConnections[0].Path)
+	}
+}
+
+func TestParseHTTPDataConnection(t *testing.T) {
+	for _, tc := range []struct {
+		fields string
+		valid  bool
+	}{
+		{"url: https://api.example.com/v1/tickets", true},
+		{"url: $TICKETS_URL", true},
+		{"url: https://api.example.com/v1/tickets\n    records: $.data.items[0].rows", true},
+		{"url: https://api.example.com/v1/tickets\n    auth: {type: bearer, token: $TOKEN}", true},
+		{"url: https://api.example.com/v1/tickets\n    pagination: {type: cursor, cursor_path: meta.next, cursor_param: cursor}", true},
+		{"url: https://api.example.com/v1/tickets\n    format: csv\n    pagination: {type: link_header}", true},
+		{"url: ftp://example.com/tickets", false},
+		{"url: https://api.example.com/v1/tickets\n    records: data..items", false},
+		{"url: https://api.example.com/v1/tickets\n    format: xml", false},
+		{"url: https://api.example.com/v1/tickets\n    auth: {type: basic}", false},
+		{"url: https://api.example.com/v1/tickets\n    format: csv\n    pagination: {type: cursor, cursor_path: next, cursor_param: cursor}", false},
+		{"url: https://api.example.com/v1/tickets\n    format: jsonl\n    pagination: {type: cursor, cursor_path: next, cursor_param: cursor}", false},
+		{"url: https://api.example.com/v1/tickets\n    pagination: {type: page}", false},
+	} {
+		conf := &Config{}
+		err := conf.Parse([]byte(`
+data_connections:
+  - id: tickets
+    type: http
+    `+tc.fields+`
+datasets:
+  - id: all_tickets
+    data_source:
+      id: tickets
+      data_connection: tickets`), "")
+		if tc.valid && err != nil {
+			t.Errorf("%q: %s", tc.fields, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%q: expected an error", tc.fields)
+		}
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	segments, err := ParseJSONPath("$.data.items[0][2].rows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{"data", "items", 0, 2, "rows"}
+	if len(segments) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, segments)
+	}
+	for i := range expected {
+		if segments[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, segments)
+		}
+	}
+}
+
+func TestParseS3DataConnection(t *testing.T) {
+	for _, tc := range []struct {
+		fields string
+		valid  bool
+	}{
+		{"bucket: drops\n    key: orders/2026-10-01.csv", true},
+		{"bucket: drops\n    prefix: orders/\n    format: csv\n    include_source_file: true", true},
+		{"bucket: drops\n    prefix: lake/\n    format: parquet", true},
+		{"bucket: drops\n    key: events.ndjson", true},
+		{"key: orders.csv", false},
+		{"bucket: drops", false},
+		{"bucket: drops\n    key: orders.csv\n    prefix: orders/", false},
+		{"bucket: drops\n    prefix: orders/", false},
+		{"bucket: drops\n    key: orders.txt", false},
+		{"bucket: drops\n    prefix: reports/\n    format: xlsx", false},
+		{"bucket: drops\n    key: events.jsonl\n    include_source_file: true", false},
+	} {
+		conf := &Config{}
+		err := conf.Parse([]byte(`
+data_connections:
+  - id: drops
+    type: s3
+    `+tc.fields+`
+datasets:
+  - id: all_drops
+    data_source:
+      id: drops
+      data_connection: drops`), "")
+		if tc.valid && err != nil {
+			t.Errorf("%q: %s", tc.fields, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%q: expected an error", tc.fields)
+		}
+	}
+}
+
+type testDataConnectionType struct{}
+
+func (testDataConnectionType) ValidateConnection(dc *DataConnection) error {
+	if dc.Options["account"] == "" {
+		return errors.New("missing account")
+	}
+	return nil
+}
+
+func (testDataConnectionType) ValidateSource(dc *DataConnection, ds *DataSource) error {
+	if ds.Query == "" {
+		return errors.New("missing report")
+	}
+	return nil
+}
+
+func TestParseRegisteredDataConnectionType(t *testing.T) {
+	RegisterDataConnectionType("test-crm", testDataConnectionType{})
+	for _, tc := range []struct {
+		options string
+		query   string
+		valid   bool
+	}{
+		{"account: acme", "open_deals", true},
+		{"region: eu", "open_deals", false},
+		{"account: acme", "", false},
+	} {
+		conf := &Config{}
+		err := conf.Parse([]byte(`
+data_connections:
+  - id: crm
+    type: test-crm
+    options:
+      `+tc.options+`
+datasets:
+  - id: deals
+    data_source:
+      id: crm_deals
+      data_connection: crm
+      query: "`+tc.query+`"`), "")
+		if tc.valid && err != nil {
+			t.Errorf("%q, %q: %s", tc.options, tc.query, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%q, %q: expected an error", tc.options, tc.query)
+		}
+	}
+	if conf := (&Config{}); conf.Parse([]byte(`
+data_connections:
+  - id: crm
+    type: unregistered-crm`), "") == nil {
+		t.Error("expected an error for an unregistered type")
+	}
+}
+
+func TestParseDataSourceColumns(t *testing.T) {
+	for _, tc := range []struct {
+		columns string
+		valid   bool
+	}{
+		{"zip: text\n        amount: real", true},
+		{"ordered_at: timestamp\n        active: boolean\n        day: date\n        id: integer", true},
+		{"amount: money", false},
+	} {
+		conf := &Config{}
+		err := conf.Parse([]byte(`
+data_connections:
+  - id: orders
+    type: csv
+    path: orders.csv
+datasets:
+  - id: all_orders
+    data_source:
+      id: orders
+      data_connection: orders
+      columns:
+        `+tc.columns), "")
+		if tc.valid && err != nil {
+			t.Errorf("%q: %s", tc.columns, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%q: expected an error", tc.columns)
+		}
+	}
+}
+
+func TestParseIncrementalDataSource(t *testing.T) {
+	for _, tc := range []struct {
+		connection  string
+		incremental string
+		valid       bool
+	}{
+		{"postgres", "cursor_column: updated_at\n        primary_key: [id]\n        initial_value: 1970-01-01", true},
+		{"postgres", "cursor_column: id\n        primary_key: [region, id]\n        initial_value: 0", true},
+		{"postgres", "primary_key: [id]\n        initial_value: 0", false},
+		{"postgres", "cursor_column: id\n        initial_value: 0", false},
+		{"postgres", "cursor_column: id\n        primary_key: [id]", false},
+		{"csv", "cursor_column: id\n        primary_key: [id]\n        initial_value: 0", false},
+	} {
+		conf := &Config{}
+		err := conf.Parse([]byte(`
+data_connections:
+  - id: postgres
+    type: postgres
+    connection_string: postgres://localhost/app
+  - id: csv
+    type: csv
+    path: orders.csv
+datasets:
+  - id: orders
+    data_source:
+      id: new_orders
+      data_connection: `+tc.connection+`
+      query: SELECT * FROM orders WHERE updated_at >= $1
+      incremental:
+        `+tc.incremental), "")
+		if tc.valid && err != nil {
+			t.Errorf("%s %q: %s", tc.connection, tc.incremental, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s %q: expected an error", tc.connection, tc.incremental)
+		}
+	}
+}
+
+func TestParseDatasetTransform(t *testing.T) {
+	for _, tc := range []struct {
+		fields string
+		valid  bool
+	}{
+		{`select: ['orders."Order ID"', 'returns.Returned AS returned']`, true},
+		{"transform: SELECT region, SUM(amount) AS total FROM orders LEFT JOIN returns USING (id) GROUP BY region;", true},
+		{"transform: |\n      WITH big AS (SELECT * FROM orders WHERE amount > 100)\n      SELECT * FROM big -- large orders", true},
+		{"select: ['']", false},
+		{"select: [id, ' ']", false},
+		{"select: [id]\n    transform: SELECT id FROM orders", false},
+	} {
+		conf := &Config{}
+		err := conf.Parse([]byte(`
+data_connections:
+  - id: orders
+    type: csv
+    path: orders.csv
+  - id: returns
+    type: csv
+    path: returns.csv
+datasets:
+  - id: orders_and_returns
+    data_source:
+      id: orders
+      data_connection: orders
+    joins:
+      - type: LEFT JOIN
+        columns:
+          - left_column: id
+            right_column: id
+        data_source:
+          id: returns
+          data_connection: returns
+    `+tc.fields), "")
+		if tc.valid && err != nil {
+			t.Errorf("%q: %s", tc.fields, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%q: expected an error", tc.fields)
+		}
+	}
+}
+
+func TestParseDatasetFromDataset(t *testing.T) {
+	for _, tc := range []struct {
+		datasets string
+		valid    bool
+	}{
+		{`
+  - id: returned
+    data_source:
+      id: returned_orders
+      dataset: orders
+      query: SELECT * FROM orders WHERE returned
+  - id: totals
+    data_source:
+      id: returned_totals
+      dataset: returned
+    transform: SELECT COUNT(*) AS n FROM returned_totals`, true},
+		{`
+  - id: returned
+    data_source:
+      id: returned_orders
+      dataset: refunds`, false},
+		{`
+  - id: returned
+    data_source:
+      id: returned_orders
+      dataset: returned`, false},
+		{`
+  - id: returned
+    data_source:
+      id: returned_orders
+      dataset: totals
+  - id: totals
+    data_source:
+      id: returned_totals
+      dataset: returned`, false},
+		{`
+  - id: returned
+    data_source:
+      id: returned_orders
+      data_connection: orders
+      dataset: orders`, false},
+	} {
+		conf := &Config{}
+		err := conf.Parse([]byte(`
+data_connections:
+  - id: orders
+    type: csv
+    path: orders.csv
+datasets:
+  - id: orders
+    data_source:
+      id: all_orders
+      data_connection: orders`+tc.datasets), "")
+		if tc.valid && err != nil {
+			t.Errorf("%q: %s", tc.datasets, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%q: expected an error", tc.datasets)
+		}
+	}
+}
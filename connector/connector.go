@@ -0,0 +1,154 @@
+// Package connector is the registry of data connection types. Data
+// connectors are registered at build time, usually from the init function
+// of the package that implements them:
+//
+//	func init() {
+//		err := connector.Register("salesforce", salesforceConnector{})
+//		if err != nil {
+//			panic(err)
+//		}
+//	}
+//
+// and are used by data connections with the same type. Types can only be
+// registered once, so the built-in types can't be replaced.
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/crossjoin-io/crossjoin/config"
+)
+
+// DataConnector reads data sources from a type of data connection.
+type DataConnector interface {
+	// ValidateConnection checks the config of a data connection when the
+	// config is loaded. Settings that aren't fields of the data
+	// connection can be read from its Options.
+	ValidateConnection(dc *config.DataConnection) error
+	// ValidateSource checks the config of a data source that reads from
+	// the data connection.
+	ValidateSource(dc *config.DataConnection, ds *config.DataSource) error
+	// Open starts reading the rows of a data source.
+	Open(ctx context.Context, dc *config.DataConnection, ds *config.DataSource) (Rows, error)
+}
+
+// IncrementalConnector is implemented by data connectors that can read
+// only the rows past a cursor, for incremental data sources.
+type IncrementalConnector interface {
+	DataConnector
+	// OpenIncremental starts reading the rows of a data source whose
+	// cursor column is past the cursor.
+	OpenIncremental(ctx context.Context, dc *config.DataConnection, ds *config.DataSource, cursor interface{}) (Rows, error)
+}
+
+// Column is a column of the rows read by a data connector.
+type Column struct {
+	Name string
+	// Type is the SQLite type of the column. Columns without a type can
+	// store any value.
+	Type string
+}
+
+// Rows are the rows of a data source. Values of rows can be nil, bool,
+// int64, float64, string, []byte or time.Time.
+type Rows interface {
+	Columns() []Column
+	// Next advances to the next row. It returns false when there are no
+	// rows left or reading the next row failed.
+	Next() bool
+	// Values returns the values of the current row, in the order of the
+	// columns.
+	Values() []interface{}
+	// Err returns the error that stopped Next, if any.
+	Err() error
+	Close() error
+}
+
+var (
+	mu         sync.RWMutex
+	connectors = map[string]DataConnector{}
+)
+
+// Register makes a data connector available for data connections of a
+// type. It returns an error if the type is already registered.
+func Register(name string, c DataConnector) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if c == nil {
+		return fmt.Errorf("nil data connector for `%s`", name)
+	}
+	if _, ok := connectors[name]; ok {
+		return fmt.Errorf("data connection type `%s` is already registered", name)
+	}
+	connectors[name] = c
+	config.RegisterDataConnectionType(name, c)
+	return nil
+}
+
+// Lookup returns the data connector of a data connection type.
+func Lookup(name string) (DataConnector, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := connectors[name]
+	return c, ok
+}
+
+// Types returns the registered data connection types, sorted.
+func Types() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(connectors))
+	for name := range connectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open reads a data source with the data connector of its data connection.
+func Open(ctx context.Context, dc *config.DataConnection, ds *config.DataSource) (Rows, error) {
+	c, ok := Lookup(dc.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown data connection type `%s`", dc.Type)
+	}
+	return c.Open(ctx, dc, ds)
+}
+
+// NewRows returns rows of records that have already been read.
+func NewRows(columns []Column, records [][]interface{}) Rows {
+	return &recordRows{columns: columns, records: records, index: -1}
+}
+
+type recordRows struct {
+	columns []Column
+	records [][]interface{}
+	index   int
+}
+
+func (r *recordRows) Columns() []Column { return r.columns }
+
+func (r *recordRows) Next() bool {
+	if r.index+1 >= len(r.records) {
+		return false
+	}
+	r.index++
+	return true
+}
+
+func (r *recordRows) Values() []interface{} { return r.records[r.index] }
+
+func (r *recordRows) Err() error { return nil }
+
+func (r *recordRows) Close() error { return nil }
+
+// UntypedColumns returns columns without types.
+func UntypedColumns(names []string) []Column {
+	columns := make([]Column, len(names))
+	for i, name := range names {
+		columns[i] = Column{Name: name}
+	}
+	return columns
+}
@@ -0,0 +1,41 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossjoin-io/crossjoin/config"
+)
+
+type testConnector struct{}
+
+func (testConnector) ValidateConnection(dc *config.DataConnection) error { return nil }
+
+func (testConnector) ValidateSource(dc *config.DataConnection, ds *config.DataSource) error {
+	return nil
+}
+
+func (testConnector) Open(ctx context.Context, dc *config.DataConnection, ds *config.DataSource) (Rows, error) {
+	return NewRows(nil, nil), nil
+}
+
+func TestRegister(t *testing.T) {
+	err := Register("test", testConnector{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Lookup("test"); !ok {
+		t.Error("expected the connector to be registered")
+	}
+	if _, ok := config.LookupDataConnectionType("test"); !ok {
+		t.Error("expected the validator to be registered")
+	}
+	err = Register("test", testConnector{})
+	if err == nil {
+		t.Error("expected an error for a type that's already registered")
+	}
+	err = Register("nil", nil)
+	if err == nil {
+		t.Error("expected an error for a nil connector")
+	}
+}
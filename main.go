@@ -2,6 +2,8 @@ package main
 
 import (
 	"log"
+	// Embed time zone data for schedules, since the Docker image doesn't have it.
+	_ "time/tzdata"
 
 	"github.com/crossjoin-io/crossjoin/cmd"
 )
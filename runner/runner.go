@@ -8,6 +8,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -21,12 +22,18 @@ import (
 // containers.
 type Runner struct {
 	apiURL string
+	name   string
 }
 
 // NewRunner returns a new runner instance.
 func NewRunner(apiURL string) (*Runner, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
 	return &Runner{
 		apiURL: apiURL,
+		name:   fmt.Sprintf("%s-%d", hostname, os.Getpid()),
 	}, nil
 }
 
@@ -62,7 +69,7 @@ func (run *Runner) Start() error {
 }
 
 func (run *Runner) pollForTask() (*api.Task, error) {
-	resp, err := http.Get(run.apiURL + "/api/tasks/poll")
+	resp, err := http.Get(run.apiURL + "/api/tasks/poll?runner=" + url.QueryEscape(run.name))
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -89,8 +96,8 @@ func (run *Runner) pollForTask() (*api.Task, error) {
 	return decodedResponse.Response, nil
 }
 
-func (run *Runner) downloadDataset(dataset string, destinationDirectory string) error {
-	resp, err := http.Get(run.apiURL + fmt.Sprintf("/api/datasets/%s/download", dataset))
+func (run *Runner) downloadDataset(dataset, version string, destinationDirectory string) error {
+	resp, err := http.Get(run.apiURL + fmt.Sprintf("/api/datasets/%s/download?version=%s", dataset, url.QueryEscape(version)))
 	if err != nil {
 		log.Println(err)
 		return err
@@ -144,7 +151,7 @@ func (run *Runner) runTaskContainer(t *api.Task) (*api.TaskResult, error) {
 
 	for _, dataset := range t.Datasets {
 		// Download each dataset
-		err = run.downloadDataset(dataset, dir)
+		err = run.downloadDataset(dataset, t.DatasetVersions[dataset], dir)
 		if err != nil {
 			log.Println(err)
 			return nil, err
@@ -176,12 +183,15 @@ func (run *Runner) runTaskContainer(t *api.Task) (*api.TaskResult, error) {
 	cmd.Stderr = &stderr
 	err = cmd.Run()
 	resultOK := true
+	exitCode := 0
 	if err != nil {
 		log.Println(err)
-		if _, ok := err.(*exec.ExitError); !ok {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
 			return nil, err
 		}
 		resultOK = false
+		exitCode = exitErr.ExitCode()
 	}
 	if stdout.Len() > 512 {
 		stdout.Truncate(512)
@@ -199,11 +209,13 @@ func (run *Runner) runTaskContainer(t *api.Task) (*api.TaskResult, error) {
 		}
 	}
 	return &api.TaskResult{
-		ID:     t.ID,
-		OK:     resultOK,
-		Stdout: stdout.String(),
-		Stderr: stderr.String(),
-		Output: output,
+		ID:        t.ID,
+		AttemptID: t.AttemptID,
+		OK:        resultOK,
+		ExitCode:  &exitCode,
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Output:    output,
 	}, nil
 }
 